@@ -2,8 +2,10 @@ package client
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"sort"
 	"strings"
 	"time"
@@ -148,13 +150,70 @@ func (c *Client) CallService(domain, service string, data map[string]any) ([]Sta
 	return states, nil
 }
 
-// GetHistory fetches the history for an entity over a time range.
-// start is an RFC3339 timestamp; duration is added to produce the end time.
-func (c *Client) GetHistory(entityID string, start time.Time, duration time.Duration) ([][]HistoryEntry, error) {
-	end := start.Add(duration)
+// ServiceResponse is the result of a service call made with return_response.
+type ServiceResponse struct {
+	Response      json.RawMessage `json:"response"`
+	ChangedStates []State         `json:"changed_states"`
+}
+
+// CallServiceWithResponse calls a HA service that returns data (e.g.
+// weather.get_forecasts) and returns its response alongside changed states.
+func (c *Client) CallServiceWithResponse(domain, service string, data map[string]any) (*ServiceResponse, error) {
+	resp, err := c.r.R().SetBody(data).SetQueryParam("return_response", "true").
+		Post(fmt.Sprintf("/api/services/%s/%s", domain, service))
+	if err != nil {
+		return nil, fmt.Errorf("connection error: %w", err)
+	}
+	if resp.StatusCode() == http.StatusUnauthorized {
+		return nil, fmt.Errorf("unauthorized: check your HASS_TOKEN")
+	}
+	if resp.StatusCode() == http.StatusNotFound {
+		return nil, fmt.Errorf("service not found: %s.%s", domain, service)
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode(), resp.String())
+	}
+	return parseServiceResponse(resp.Body())
+}
+
+// parseServiceResponse unwraps the body of a return_response call.
+//
+// HA 2024.x+ wraps the service result under "service_response":
+//
+//	{"service_response": {...}, "changed_states": [...]}
+//
+// Some versions used "response"; older ones return the result directly.
+func parseServiceResponse(body []byte) (*ServiceResponse, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse service response: %w", err)
+	}
+	result := &ServiceResponse{Response: json.RawMessage(body)}
+	for _, key := range []string{"service_response", "response"} {
+		if data, ok := raw[key]; ok {
+			result.Response = data
+			break
+		}
+	}
+	if changed, ok := raw["changed_states"]; ok {
+		if err := json.Unmarshal(changed, &result.ChangedStates); err != nil {
+			return nil, fmt.Errorf("failed to parse changed states: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// GetHistory fetches the history for entities between start and end.
+// The result holds one list of states per entity that has history. For most
+// domains Home Assistant returns only state changes; with attributeChanges,
+// attribute-only updates are included too.
+func (c *Client) GetHistory(entityIDs []string, start, end time.Time, attributeChanges bool) ([][]HistoryEntry, error) {
 	req := c.r.R().
-		SetQueryParam("filter_entity_id", entityID).
+		SetQueryParam("filter_entity_id", strings.Join(entityIDs, ",")).
 		SetQueryParam("end_time", end.UTC().Format(time.RFC3339))
+	if attributeChanges {
+		req.SetQueryParam("significant_changes_only", "0")
+	}
 
 	resp, err := req.Get("/api/history/period/" + start.UTC().Format(time.RFC3339))
 	if err != nil {
@@ -189,6 +248,47 @@ func (c *Client) GetConfig() (map[string]any, error) {
 	return cfg, nil
 }
 
+// RenderTemplate renders a Jinja template via POST /api/template and returns
+// the rendered text.
+func (c *Client) RenderTemplate(template string) (string, error) {
+	resp, err := c.r.R().SetBody(map[string]any{"template": template}).Post("/api/template")
+	if err != nil {
+		return "", fmt.Errorf("connection error: %w", err)
+	}
+	if resp.StatusCode() == http.StatusUnauthorized {
+		return "", fmt.Errorf("unauthorized: check your HASS_TOKEN")
+	}
+	if resp.StatusCode() == http.StatusBadRequest {
+		return "", fmt.Errorf("template error: %s", strings.TrimSpace(resp.String()))
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %d: %s", resp.StatusCode(), resp.String())
+	}
+	return resp.String(), nil
+}
+
+// FireEvent fires an event of the given type via POST /api/events/<type>.
+func (c *Client) FireEvent(eventType string, data map[string]any) error {
+	req := c.r.R()
+	if data != nil {
+		req.SetBody(data)
+	}
+	resp, err := req.Post("/api/events/" + url.PathEscape(eventType))
+	if err != nil {
+		return fmt.Errorf("connection error: %w", err)
+	}
+	if resp.StatusCode() == http.StatusUnauthorized {
+		return fmt.Errorf("unauthorized: check your HASS_TOKEN")
+	}
+	if resp.StatusCode() == http.StatusBadRequest {
+		return fmt.Errorf("event rejected: %s", strings.TrimSpace(resp.String()))
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode(), resp.String())
+	}
+	return nil
+}
+
 // BaseURL returns the configured base URL (useful for WebSocket client).
 func (c *Client) BaseURL() string {
 	return c.baseURL
@@ -200,9 +300,43 @@ type ServiceDomain struct {
 	Services []string `json:"services"`
 }
 
-// GetServices returns a list of domains and their available service names.
-// If domain is non-empty, only that domain is returned.
-func (c *Client) GetServices(domain string) ([]ServiceDomain, error) {
+// ServiceField describes one field a service accepts, as declared in the
+// integration's services.yaml.
+type ServiceField struct {
+	Name        string         `json:"name,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required,omitempty"`
+	Advanced    bool           `json:"advanced,omitempty"`
+	Example     any            `json:"example,omitempty"`
+	Default     any            `json:"default,omitempty"`
+	Selector    map[string]any `json:"selector,omitempty"`
+}
+
+// ServiceSchema describes a single service: its fields and target spec.
+type ServiceSchema struct {
+	Domain      string                  `json:"domain"`
+	Service     string                  `json:"service"`
+	Name        string                  `json:"name,omitempty"`
+	Description string                  `json:"description,omitempty"`
+	Fields      map[string]ServiceField `json:"fields"`
+	Target      map[string]any          `json:"target,omitempty"`
+	Response    map[string]any          `json:"response,omitempty"`
+}
+
+// rawService is one service description as returned by GET /api/services.
+// Fields may contain sections ({"collapsed": …, "fields": {…}}) that group
+// the real fields; they are flattened by parseServiceSchema.
+type rawService struct {
+	Name        string                     `json:"name"`
+	Description string                     `json:"description"`
+	Fields      map[string]json.RawMessage `json:"fields"`
+	Target      map[string]any             `json:"target"`
+	Response    map[string]any             `json:"response"`
+}
+
+// fetchServices returns the raw service descriptions, keyed by domain and
+// service name.
+func (c *Client) fetchServices() (map[string]map[string]rawService, error) {
 	resp, err := c.r.R().Get("/api/services")
 	if err != nil {
 		return nil, fmt.Errorf("connection error: %w", err)
@@ -216,28 +350,102 @@ func (c *Client) GetServices(domain string) ([]ServiceDomain, error) {
 
 	// HA returns [{domain, services: {svc_name: {fields,...}, ...}}, ...]
 	var raw []struct {
-		Domain   string                     `json:"domain"`
-		Services map[string]json.RawMessage `json:"services"`
+		Domain   string                `json:"domain"`
+		Services map[string]rawService `json:"services"`
 	}
 	if err := json.Unmarshal(resp.Body(), &raw); err != nil {
 		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
+	result := make(map[string]map[string]rawService, len(raw))
+	for _, d := range raw {
+		result[d.Domain] = d.Services
+	}
+	return result, nil
+}
+
+// GetServices returns a list of domains and their available service names.
+// If domain is non-empty, only that domain is returned.
+func (c *Client) GetServices(domain string) ([]ServiceDomain, error) {
+	services, err := c.fetchServices()
+	if err != nil {
+		return nil, err
+	}
 
 	var result []ServiceDomain
-	for _, d := range raw {
-		if domain != "" && d.Domain != domain {
+	for d, svcs := range services {
+		if domain != "" && d != domain {
 			continue
 		}
-		sd := ServiceDomain{Domain: d.Domain}
-		for svc := range d.Services {
+		sd := ServiceDomain{Domain: d}
+		for svc := range svcs {
 			sd.Services = append(sd.Services, svc)
 		}
 		sort.Strings(sd.Services)
 		result = append(result, sd)
 	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Domain < result[j].Domain })
 	return result, nil
 }
 
+// ErrServiceNotFound is returned by DescribeService for a service Home
+// Assistant does not have.
+var ErrServiceNotFound = errors.New("service not found")
+
+// DescribeService returns the full schema of domain.service.
+func (c *Client) DescribeService(domain, service string) (*ServiceSchema, error) {
+	services, err := c.fetchServices()
+	if err != nil {
+		return nil, err
+	}
+	raw, ok := services[domain][service]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s.%s", ErrServiceNotFound, domain, service)
+	}
+	return parseServiceSchema(domain, service, raw)
+}
+
+// parseServiceSchema converts a raw description into a ServiceSchema,
+// flattening field sections.
+func parseServiceSchema(domain, service string, raw rawService) (*ServiceSchema, error) {
+	schema := &ServiceSchema{
+		Domain:      domain,
+		Service:     service,
+		Name:        raw.Name,
+		Description: raw.Description,
+		Fields:      map[string]ServiceField{},
+		Target:      raw.Target,
+		Response:    raw.Response,
+	}
+	var add func(fields map[string]json.RawMessage) error
+	add = func(fields map[string]json.RawMessage) error {
+		for key, data := range fields {
+			var section struct {
+				Fields   map[string]json.RawMessage `json:"fields"`
+				Selector map[string]any             `json:"selector"`
+			}
+			if err := json.Unmarshal(data, &section); err != nil {
+				return fmt.Errorf("failed to parse field %s of %s.%s: %w", key, domain, service, err)
+			}
+			if section.Fields != nil && section.Selector == nil {
+				if err := add(section.Fields); err != nil {
+					return err
+				}
+				continue
+			}
+			var f ServiceField
+			if err := json.Unmarshal(data, &f); err != nil {
+				return fmt.Errorf("failed to parse field %s of %s.%s: %w", key, domain, service, err)
+			}
+			schema.Fields[key] = f
+		}
+		return nil
+	}
+	if err := add(raw.Fields); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
 // TodoItem represents a single item in a Home Assistant todo list.
 type TodoItem struct {
 	UID         string `json:"uid"`
@@ -267,35 +475,23 @@ func (c *Client) GetTodoItems(entityID string) ([]TodoItem, error) {
 		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode(), resp.String())
 	}
 
-	// HA 2024.x+ wraps the service result under a "response" key:
-	//   {"response": {"entity_id": {"items": [...]}}, "changed_states": [...]}
-	// Older versions return the entity map directly:
-	//   {"entity_id": {"items": [...]}}
-	var raw map[string]json.RawMessage
-	if err := json.Unmarshal(resp.Body(), &raw); err != nil {
-		return nil, fmt.Errorf("failed to parse todo response: %w", err)
+	result, err := parseServiceResponse(resp.Body())
+	if err != nil {
+		return nil, err
 	}
-	// HA wraps the result under "service_response"; fall back to direct map for
-	// any future format changes.
-	entityMap := raw
-	for _, key := range []string{"service_response", "response"} {
-		if data, ok := raw[key]; ok {
-			var inner map[string]json.RawMessage
-			if err := json.Unmarshal(data, &inner); err == nil {
-				entityMap = inner
-				break
-			}
-		}
+	var entityMap map[string]json.RawMessage
+	if err := json.Unmarshal(result.Response, &entityMap); err != nil {
+		return nil, fmt.Errorf("failed to parse todo response: %w", err)
 	}
 	entityData, ok := entityMap[entityID]
 	if !ok {
 		return nil, nil
 	}
-	var result struct {
+	var list struct {
 		Items []TodoItem `json:"items"`
 	}
-	if err := json.Unmarshal(entityData, &result); err != nil {
+	if err := json.Unmarshal(entityData, &list); err != nil {
 		return nil, fmt.Errorf("failed to parse todo items: %w", err)
 	}
-	return result.Items, nil
+	return list.Items, nil
 }
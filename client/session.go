@@ -0,0 +1,284 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrSessionClosed is returned by Session methods once the connection is gone.
+var ErrSessionClosed = errors.New("websocket session closed")
+
+// Session multiplexes commands and event subscriptions over one WebSocket
+// connection. A background goroutine reads every frame and routes it to the
+// pending command or subscription with the matching id, so callers can wait
+// for events while issuing commands on the same connection.
+//
+// Once a Session is started, the underlying WSClient must not be read from
+// directly.
+type Session struct {
+	ws *WSClient
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[int]chan []byte
+	subs    map[int]*subState
+	err     error
+	done    chan struct{}
+}
+
+// subState tracks one subscription. Only the read loop sends on or closes
+// ch; quit is closed by Unsubscribe to stop delivery.
+type subState struct {
+	ch   chan []byte
+	quit chan struct{}
+}
+
+// Subscription is an active event subscription. C receives the raw event
+// frames ({"id":…,"type":"event","event":{…}}) and is closed when the
+// connection ends.
+type Subscription struct {
+	ID int
+	C  <-chan []byte
+
+	s *Session
+}
+
+// StartSession hands the connection over to a new Session and starts
+// reading frames in the background.
+func (ws *WSClient) StartSession() *Session {
+	s := &Session{
+		ws:      ws,
+		pending: make(map[int]chan []byte),
+		subs:    make(map[int]*subState),
+		done:    make(chan struct{}),
+	}
+	go s.readLoop()
+	return s
+}
+
+func (s *Session) readLoop() {
+	defer func() {
+		s.mu.Lock()
+		for id, sub := range s.subs {
+			close(sub.ch)
+			delete(s.subs, id)
+		}
+		s.mu.Unlock()
+	}()
+	for {
+		raw, err := s.ws.ReadRaw()
+		if err != nil {
+			s.shutdown(err)
+			return
+		}
+		var head struct {
+			ID   int    `json:"id"`
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(raw, &head); err != nil {
+			continue
+		}
+		s.mu.Lock()
+		switch head.Type {
+		case "result":
+			ch := s.pending[head.ID]
+			delete(s.pending, head.ID)
+			s.mu.Unlock()
+			if ch != nil {
+				ch <- raw // buffered; each command gets exactly one result
+			}
+		case "event":
+			sub := s.subs[head.ID]
+			s.mu.Unlock()
+			if sub == nil {
+				continue
+			}
+			select {
+			case sub.ch <- raw:
+			case <-sub.quit:
+			case <-s.done:
+				return
+			}
+		default:
+			s.mu.Unlock()
+		}
+	}
+}
+
+func (s *Session) shutdown(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return
+	}
+	s.err = err
+	close(s.done)
+	for id := range s.pending {
+		delete(s.pending, id)
+	}
+}
+
+// Done is closed when the connection fails or the session is closed.
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns the error that ended the session, if any.
+func (s *Session) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close ends the session and closes the underlying connection.
+func (s *Session) Close() error {
+	s.shutdown(ErrSessionClosed)
+	return s.ws.Close()
+}
+
+// send assigns the next message id to payload, lets register record where
+// the response should go, and writes the payload.
+func (s *Session) send(payload map[string]any, register func(id int)) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.mu.Lock()
+	if s.err != nil {
+		s.mu.Unlock()
+		return ErrSessionClosed
+	}
+	s.ws.counter++
+	id := s.ws.counter
+	register(id)
+	s.mu.Unlock()
+
+	payload["id"] = id
+	if err := s.ws.WriteJSON(payload); err != nil {
+		return fmt.Errorf("websocket write error: %w", err)
+	}
+	return nil
+}
+
+// CallRaw sends a command and returns the raw result frame.
+// The payload must include a "type" key. The message ID is set automatically.
+func (s *Session) CallRaw(payload map[string]any) ([]byte, error) {
+	ch := make(chan []byte, 1)
+	err := s.send(payload, func(id int) { s.pending[id] = ch })
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case raw := <-ch:
+		return raw, nil
+	case <-s.done:
+		return nil, s.Err()
+	}
+}
+
+// Call sends a command and returns the parsed result frame.
+func (s *Session) Call(payload map[string]any) (*WSMessage, error) {
+	raw, err := s.CallRaw(payload)
+	if err != nil {
+		return nil, err
+	}
+	var msg WSMessage
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return &msg, nil
+}
+
+// Subscribe sends a subscription command (subscribe_events,
+// subscribe_trigger, …) and waits for HA to acknowledge it.
+func (s *Session) Subscribe(payload map[string]any) (*Subscription, error) {
+	sub := &subState{ch: make(chan []byte, 256), quit: make(chan struct{})}
+	ack := make(chan []byte, 1)
+	var subID int
+	err := s.send(payload, func(id int) {
+		subID = id
+		s.subs[id] = sub
+		s.pending[id] = ack
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var raw []byte
+	select {
+	case raw = <-ack:
+	case <-s.done:
+		return nil, s.Err()
+	}
+	var msg WSMessage
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if msg.Success != nil && !*msg.Success {
+		s.mu.Lock()
+		delete(s.subs, subID)
+		s.mu.Unlock()
+		close(sub.quit)
+		return nil, fmt.Errorf("subscription failed: %v", msg.Error)
+	}
+	return &Subscription{ID: subID, C: sub.ch, s: s}, nil
+}
+
+// SubscribeEvents subscribes to HA events, optionally filtered by eventType.
+// Pass empty string to receive all events.
+func (s *Session) SubscribeEvents(eventType string) (*Subscription, error) {
+	payload := map[string]any{"type": "subscribe_events"}
+	if eventType != "" {
+		payload["event_type"] = eventType
+	}
+	return s.Subscribe(payload)
+}
+
+// Unsubscribe stops delivery of further events. C is not closed.
+func (sub *Subscription) Unsubscribe() error {
+	s := sub.s
+	s.mu.Lock()
+	st, ok := s.subs[sub.ID]
+	delete(s.subs, sub.ID)
+	s.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	close(st.quit)
+	_, err := s.Call(map[string]any{
+		"type":         "unsubscribe_events",
+		"subscription": sub.ID,
+	})
+	return err
+}
+
+// CallService calls a HA service over the WebSocket API and returns the id
+// of the context HA created for the call. State changes caused by the call
+// carry this context id (or have it as their parent), which lets callers tell
+// them apart from unrelated changes.
+func (s *Session) CallService(domain, service string, data map[string]any) (string, error) {
+	msg, err := s.Call(map[string]any{
+		"type":         "call_service",
+		"domain":       domain,
+		"service":      service,
+		"service_data": data,
+	})
+	if err != nil {
+		return "", err
+	}
+	if msg.Success != nil && !*msg.Success {
+		if code, _ := msg.Error["code"].(string); code == "not_found" {
+			return "", fmt.Errorf("service not found: %s.%s", domain, service)
+		}
+		if text, _ := msg.Error["message"].(string); text != "" {
+			return "", fmt.Errorf("service call failed: %s", text)
+		}
+		return "", fmt.Errorf("service call failed: %v", msg.Error)
+	}
+	result, _ := msg.Result.(map[string]any)
+	ctx, _ := result["context"].(map[string]any)
+	id, _ := ctx["id"].(string)
+	return id, nil
+}
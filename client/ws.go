@@ -116,6 +116,50 @@ func (ws *WSClient) Close() error {
 	return ws.conn.Close()
 }
 
+// Event is a Home Assistant event as delivered over the WebSocket API.
+type Event struct {
+	EventType string          `json:"event_type"`
+	Data      json.RawMessage `json:"data"`
+	Origin    string          `json:"origin,omitempty"`
+	TimeFired time.Time       `json:"time_fired"`
+	Context   map[string]any  `json:"context,omitempty"`
+}
+
+// StateChange is the data payload of a state_changed event. OldState is nil
+// for new entities and NewState is nil for removed ones.
+type StateChange struct {
+	EntityID string `json:"entity_id"`
+	OldState *State `json:"old_state"`
+	NewState *State `json:"new_state"`
+}
+
+// ParseEvent extracts the event from a raw {"type":"event"} frame.
+func ParseEvent(raw []byte) (*Event, error) {
+	var frame struct {
+		Type  string `json:"type"`
+		Event *Event `json:"event"`
+	}
+	if err := json.Unmarshal(raw, &frame); err != nil {
+		return nil, fmt.Errorf("failed to parse event: %w", err)
+	}
+	if frame.Type != "event" || frame.Event == nil {
+		return nil, fmt.Errorf("not an event frame: %s", frame.Type)
+	}
+	return frame.Event, nil
+}
+
+// StateChange decodes the data of a state_changed event.
+func (e *Event) StateChange() (*StateChange, error) {
+	if e.EventType != "state_changed" {
+		return nil, fmt.Errorf("not a state_changed event: %s", e.EventType)
+	}
+	var sc StateChange
+	if err := json.Unmarshal(e.Data, &sc); err != nil {
+		return nil, fmt.Errorf("failed to parse state_changed data: %w", err)
+	}
+	return &sc, nil
+}
+
 // MarshalEvent returns a compact JSON representation of an event message.
 func MarshalEvent(msg *WSMessage) ([]byte, error) {
 	return json.Marshal(msg.Event)
@@ -268,6 +312,192 @@ func (ws *WSClient) FetchEntityRegistry() (*EntityRegistryData, error) {
 	return data, nil
 }
 
+// EntityTarget locates an entity in the registries, for expanding service
+// targets. AreaID is the effective area, inherited from the device when the
+// entity has none of its own; Labels include the device's labels.
+type EntityTarget struct {
+	EntityID string   `json:"entity_id"`
+	DeviceID string   `json:"device_id,omitempty"`
+	AreaID   string   `json:"area_id,omitempty"`
+	Labels   []string `json:"labels,omitempty"`
+}
+
+// Device is a device registry entry.
+type Device struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	NameByUser string   `json:"name_by_user,omitempty"`
+	AreaID     string   `json:"area_id,omitempty"`
+	Labels     []string `json:"labels,omitempty"`
+}
+
+// Label is a label registry entry.
+type Label struct {
+	LabelID string `json:"label_id"`
+	Name    string `json:"name"`
+}
+
+// TargetIndex holds the registry data needed to resolve area, device and
+// label targets to entities.
+type TargetIndex struct {
+	Entities []EntityTarget
+	Areas    []Area
+	Devices  []Device
+	Labels   []Label
+}
+
+// FetchTargetIndex fetches the entity, device, area and label registries.
+// The label registry is optional: older HA versions do not have one.
+func (ws *WSClient) FetchTargetIndex() (*TargetIndex, error) {
+	var entities []struct {
+		EntityID string   `json:"entity_id"`
+		DeviceID string   `json:"device_id"`
+		AreaID   string   `json:"area_id"`
+		Labels   []string `json:"labels"`
+	}
+	if err := ws.listRegistry("config/entity_registry/list", &entities); err != nil {
+		return nil, err
+	}
+	idx := &TargetIndex{}
+	if err := ws.listRegistry("config/device_registry/list", &idx.Devices); err != nil {
+		return nil, err
+	}
+	if err := ws.listRegistry("config/area_registry/list", &idx.Areas); err != nil {
+		return nil, err
+	}
+	if err := ws.listRegistry("config/label_registry/list", &idx.Labels); err != nil {
+		idx.Labels = nil
+	}
+
+	devices := make(map[string]Device, len(idx.Devices))
+	for _, d := range idx.Devices {
+		devices[d.ID] = d
+	}
+	for _, e := range entities {
+		t := EntityTarget{EntityID: e.EntityID, DeviceID: e.DeviceID, AreaID: e.AreaID, Labels: e.Labels}
+		if d, ok := devices[e.DeviceID]; ok {
+			if t.AreaID == "" {
+				t.AreaID = d.AreaID
+			}
+			t.Labels = append(t.Labels, d.Labels...)
+		}
+		idx.Entities = append(idx.Entities, t)
+	}
+	return idx, nil
+}
+
+// listRegistry sends a registry list command and decodes its result into out.
+func (ws *WSClient) listRegistry(cmdType string, out any) error {
+	ws.counter++
+	if err := ws.conn.WriteJSON(map[string]any{
+		"id":   ws.counter,
+		"type": cmdType,
+	}); err != nil {
+		return fmt.Errorf("websocket write error: %w", err)
+	}
+	raw, err := ws.ReadRaw()
+	if err != nil {
+		return err
+	}
+	var msg struct {
+		Success bool            `json:"success"`
+		Result  json.RawMessage `json:"result"`
+		Error   map[string]any  `json:"error,omitempty"`
+	}
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return fmt.Errorf("failed to parse %s response: %w", cmdType, err)
+	}
+	if !msg.Success {
+		return fmt.Errorf("%s request failed: %v", cmdType, msg.Error)
+	}
+	if err := json.Unmarshal(msg.Result, out); err != nil {
+		return fmt.Errorf("failed to parse %s response: %w", cmdType, err)
+	}
+	return nil
+}
+
+// Statistic is one bucket of long-term statistics. Fields the statistic does
+// not track (mean for energy meters, sum for temperatures) are nil.
+type Statistic struct {
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Mean   *float64  `json:"mean,omitempty"`
+	Min    *float64  `json:"min,omitempty"`
+	Max    *float64  `json:"max,omitempty"`
+	Sum    *float64  `json:"sum,omitempty"`
+	State  *float64  `json:"state,omitempty"`
+	Change *float64  `json:"change,omitempty"`
+}
+
+// StatisticsDuringPeriod fetches the long-term statistics of statistic IDs
+// (entity IDs for sensors) from start until now, in buckets of period:
+// 5minute, hour, day, week or month.
+func (ws *WSClient) StatisticsDuringPeriod(statisticIDs []string, period string, start time.Time) (map[string][]Statistic, error) {
+	ws.counter++
+	if err := ws.conn.WriteJSON(map[string]any{
+		"id":            ws.counter,
+		"type":          "recorder/statistics_during_period",
+		"statistic_ids": statisticIDs,
+		"period":        period,
+		"start_time":    start.UTC().Format(time.RFC3339),
+		"types":         []string{"mean", "min", "max", "sum", "state", "change"},
+	}); err != nil {
+		return nil, fmt.Errorf("websocket write error: %w", err)
+	}
+	raw, err := ws.ReadRaw()
+	if err != nil {
+		return nil, err
+	}
+	var msg struct {
+		Success bool                         `json:"success"`
+		Result  map[string][]json.RawMessage `json:"result"`
+		Error   map[string]any               `json:"error,omitempty"`
+	}
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return nil, fmt.Errorf("failed to parse statistics response: %w", err)
+	}
+	if !msg.Success {
+		return nil, fmt.Errorf("statistics request failed: %v", msg.Error)
+	}
+
+	out := make(map[string][]Statistic, len(msg.Result))
+	for id, rows := range msg.Result {
+		for _, row := range rows {
+			var r struct {
+				Start  any      `json:"start"`
+				End    any      `json:"end"`
+				Mean   *float64 `json:"mean"`
+				Min    *float64 `json:"min"`
+				Max    *float64 `json:"max"`
+				Sum    *float64 `json:"sum"`
+				State  *float64 `json:"state"`
+				Change *float64 `json:"change"`
+			}
+			if err := json.Unmarshal(row, &r); err != nil {
+				return nil, fmt.Errorf("failed to parse statistics response: %w", err)
+			}
+			out[id] = append(out[id], Statistic{
+				Start: statisticTime(r.Start), End: statisticTime(r.End),
+				Mean: r.Mean, Min: r.Min, Max: r.Max, Sum: r.Sum, State: r.State, Change: r.Change,
+			})
+		}
+	}
+	return out, nil
+}
+
+// statisticTime converts a statistics timestamp: milliseconds since the
+// epoch, or an ISO 8601 string on Home Assistant before 2023.3.
+func statisticTime(v any) time.Time {
+	switch t := v.(type) {
+	case float64:
+		return time.UnixMilli(int64(t)).UTC()
+	case string:
+		parsed, _ := time.Parse(time.RFC3339Nano, t)
+		return parsed
+	}
+	return time.Time{}
+}
+
 // CallCommand sends a generic command and reads the result.
 // The payload must include a "type" key. The message ID is set automatically.
 func (ws *WSClient) CallCommand(payload map[string]any) (*WSMessage, error) {
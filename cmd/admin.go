@@ -28,9 +28,9 @@ func wsErrMsg(errMap map[string]any) string {
 	return "unknown error"
 }
 
-// wsCommand dials a WebSocket connection, sends the given payload, reads the
-// response, and closes the connection. The payload must include a "type" key.
-func wsCommand(payload map[string]any) (*client.WSMessage, error) {
+// dialWS opens an authenticated WebSocket connection using the configured
+// URL and token.
+func dialWS() (*client.WSClient, error) {
 	token := viper.GetString("hass_token")
 	if token == "" {
 		return nil, fmt.Errorf("HASS_TOKEN is required")
@@ -45,6 +45,16 @@ func wsCommand(payload map[string]any) (*client.WSMessage, error) {
 	if err != nil {
 		return nil, fmt.Errorf("websocket: %w", err)
 	}
+	return ws, nil
+}
+
+// wsCommand dials a WebSocket connection, sends the given payload, reads the
+// response, and closes the connection. The payload must include a "type" key.
+func wsCommand(payload map[string]any) (*client.WSMessage, error) {
+	ws, err := dialWS()
+	if err != nil {
+		return nil, err
+	}
 	defer ws.Close()
 
 	return ws.CallCommand(payload)
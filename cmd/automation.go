@@ -68,15 +68,15 @@ var automationListCmd = &cobra.Command{
 }
 
 var automationTriggerCmd = &cobra.Command{
-	Use:   "trigger <automation_id>",
+	Use:   "trigger <automation>",
 	Short: "Trigger an automation",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		entityID := ensureAutomationPrefix(args[0])
-		if !entityFilter.IsAllowed(entityID) {
-			return output.Err("entity not found: %s", entityID)
+		entityID, err := resolveEntity(args[0], "automation")
+		if err != nil {
+			return output.Err("%s", err)
 		}
-		_, err := getClient().CallService("automation", "trigger", map[string]any{
+		_, err = getClient().CallService("automation", "trigger", map[string]any{
 			"entity_id": entityID,
 		})
 		if err != nil {
@@ -94,15 +94,15 @@ var automationTriggerCmd = &cobra.Command{
 }
 
 var automationEnableCmd = &cobra.Command{
-	Use:   "enable <automation_id>",
+	Use:   "enable <automation>",
 	Short: "Enable an automation",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		entityID := ensureAutomationPrefix(args[0])
-		if !entityFilter.IsAllowed(entityID) {
-			return output.Err("entity not found: %s", entityID)
+		entityID, err := resolveEntity(args[0], "automation")
+		if err != nil {
+			return output.Err("%s", err)
 		}
-		_, err := getClient().CallService("automation", "turn_on", map[string]any{
+		_, err = getClient().CallService("automation", "turn_on", map[string]any{
 			"entity_id": entityID,
 		})
 		if err != nil {
@@ -120,15 +120,15 @@ var automationEnableCmd = &cobra.Command{
 }
 
 var automationDisableCmd = &cobra.Command{
-	Use:   "disable <automation_id>",
+	Use:   "disable <automation>",
 	Short: "Disable an automation",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		entityID := ensureAutomationPrefix(args[0])
-		if !entityFilter.IsAllowed(entityID) {
-			return output.Err("entity not found: %s", entityID)
+		entityID, err := resolveEntity(args[0], "automation")
+		if err != nil {
+			return output.Err("%s", err)
 		}
-		_, err := getClient().CallService("automation", "turn_off", map[string]any{
+		_, err = getClient().CallService("automation", "turn_off", map[string]any{
 			"entity_id": entityID,
 		})
 		if err != nil {
@@ -151,10 +151,3 @@ func init() {
 	automationCmd.AddCommand(automationEnableCmd)
 	automationCmd.AddCommand(automationDisableCmd)
 }
-
-func ensureAutomationPrefix(id string) string {
-	if strings.HasPrefix(id, "automation.") {
-		return id
-	}
-	return "automation." + id
-}
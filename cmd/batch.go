@@ -0,0 +1,589 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joaobarroca93/hactl/client"
+	"github.com/joaobarroca93/hactl/output"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var batchCmd = &cobra.Command{
+	Use:   "batch",
+	Short: "Run a list of actions from a file",
+	Long: `Run service calls, helper state sets, waits and sleeps from a YAML file,
+in order. Steps listed under parallel run concurrently; the group finishes
+when all of them have. Service calls and the events waits listen for share
+one WebSocket connection; current states (for entity lookups, helper
+values, a wait's starting state and the --rollback snapshot) are read over
+the REST API.
+
+Every step is checked before anything runs — entities are resolved through
+the filter, services against the restricted list and the domain mismatch
+rule, and data against the service's fields and the targeted lights'
+capabilities, as service call does — so a typo on step 12 fails the batch
+before step 1 touches anything.
+
+By default the batch stops at the first failed step. --continue-on-error runs
+the remaining steps anyway. --rollback captures the state of every entity the
+batch touches before it starts and, if any step fails, restores them with
+scene.apply.
+
+File format:
+  steps:
+    - service: light.turn_on          # service call
+      entity: [light.kitchen, "hallway light"]
+      data: {brightness_pct: 60}
+    - set: input_select.house_mode    # helper value, as state set
+      value: Home
+    - wait: cover.garage              # as state wait
+      state: closed
+      timeout: 60s
+    - sleep: 2s
+    - parallel:
+        - service: switch.turn_on
+          entity: switch.coffee_maker
+        - service: media_player.volume_set
+          entity: media_player.kitchen
+          data: {volume_level: 0.3}
+
+Each step may have a name, shown in the results. Exit code 1 if any step failed.
+
+Examples:
+  hactl batch -f morning.yaml
+  hactl batch -f evening.yaml --continue-on-error --plain
+  hactl batch -f scene-test.yaml --rollback`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, _ := cmd.Flags().GetString("file")
+		if file == "" {
+			return output.Err("--file is required")
+		}
+		continueOnError, _ := cmd.Flags().GetBool("continue-on-error")
+		rollback, _ := cmd.Flags().GetBool("rollback")
+
+		raw, err := readFileOrStdin(file)
+		if err != nil {
+			return output.Err("read batch file: %s", err)
+		}
+		steps, err := parseBatchFile(raw)
+		if err != nil {
+			return output.Err("%s", err)
+		}
+		actions, err := compileBatchSteps(steps, "")
+		if err != nil {
+			return output.Err("%s", err)
+		}
+
+		ws, err := dialWS()
+		if err != nil {
+			return output.Err("%s", err)
+		}
+		sess := ws.StartSession()
+		defer sess.Close()
+
+		var before map[string]*client.State
+		if rollback {
+			before = fetchStates(touchedEntities(actions))
+		}
+
+		result := runBatch(sess, actions, continueOnError)
+		if !result.OK && rollback {
+			if err := restoreStates(sess, before); err != nil {
+				result.RollbackError = err.Error()
+			} else {
+				result.RolledBack = true
+			}
+		}
+
+		if !quiet {
+			if plain {
+				for _, line := range formatBatchPlain(result) {
+					output.PrintPlain(line)
+				}
+			} else if err := output.PrintJSON(result); err != nil {
+				return err
+			}
+		}
+		if !result.OK {
+			return output.Err("batch failed")
+		}
+		return nil
+	},
+}
+
+func init() {
+	batchCmd.Flags().StringP("file", "f", "", "YAML file with the steps to run (- for stdin)")
+	batchCmd.Flags().Bool("continue-on-error", false, "run the remaining steps after a step fails")
+	batchCmd.Flags().Bool("rollback", false, "restore the touched entities to their pre-batch states if a step fails")
+
+	rootCmd.AddCommand(batchCmd)
+}
+
+// stringList is a YAML value given either as a single string or a list.
+type stringList []string
+
+func (l *stringList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*l = stringList{node.Value}
+		return nil
+	}
+	var list []string
+	if err := node.Decode(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// batchStep is one entry of a batch file. Exactly one of Service, Set, Wait,
+// Sleep and Parallel must be given.
+type batchStep struct {
+	Name string `yaml:"name"`
+
+	Service string         `yaml:"service"`
+	Entity  stringList     `yaml:"entity"`
+	Data    map[string]any `yaml:"data"`
+
+	Set   string `yaml:"set"`
+	Value any    `yaml:"value"`
+
+	Wait    string         `yaml:"wait"`
+	State   stringList     `yaml:"state"`
+	Attr    map[string]any `yaml:"attr"`
+	Where   string         `yaml:"where"`
+	Timeout string         `yaml:"timeout"`
+	For     string         `yaml:"for"`
+
+	Sleep string `yaml:"sleep"`
+
+	Parallel []batchStep `yaml:"parallel"`
+}
+
+// parseBatchFile decodes a batch file: a mapping with a steps list, or the
+// list on its own. Unknown keys are rejected so typos do not go unnoticed.
+func parseBatchFile(raw []byte) ([]batchStep, error) {
+	var file struct {
+		Steps []batchStep `yaml:"steps"`
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil {
+		var list []batchStep
+		listDec := yaml.NewDecoder(bytes.NewReader(raw))
+		listDec.KnownFields(true)
+		if listErr := listDec.Decode(&list); listErr != nil {
+			return nil, fmt.Errorf("invalid batch file: %s", err)
+		}
+		file.Steps = list
+	}
+	if len(file.Steps) == 0 {
+		return nil, fmt.Errorf("batch file has no steps")
+	}
+	return file.Steps, nil
+}
+
+// batchAction is a checked, ready-to-run step.
+type batchAction struct {
+	step     string // position, e.g. "3" or "4.2"
+	name     string
+	label    string // what the step does, e.g. "service light.turn_on"
+	entities []string
+	run      func(sess *client.Session) ([]client.State, error)
+	children []*batchAction
+}
+
+// compileBatchSteps checks steps and turns them into actions. prefix is the
+// position of the enclosing parallel group, if any.
+func compileBatchSteps(steps []batchStep, prefix string) ([]*batchAction, error) {
+	actions := make([]*batchAction, 0, len(steps))
+	for i, s := range steps {
+		pos := strconv.Itoa(i + 1)
+		if prefix != "" {
+			pos = prefix + "." + pos
+		}
+		a, err := compileBatchStep(s, pos)
+		if err != nil {
+			return nil, fmt.Errorf("step %s: %s", pos, err)
+		}
+		actions = append(actions, a)
+	}
+	return actions, nil
+}
+
+func compileBatchStep(s batchStep, pos string) (*batchAction, error) {
+	kinds := 0
+	for _, set := range []bool{s.Service != "", s.Set != "", s.Wait != "", s.Sleep != "", s.Parallel != nil} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return nil, fmt.Errorf("give exactly one of service, set, wait, sleep or parallel")
+	}
+
+	a := &batchAction{step: pos, name: s.Name}
+	switch {
+	case s.Service != "":
+		if err := compileServiceStep(a, s); err != nil {
+			return nil, err
+		}
+
+	case s.Set != "":
+		id, err := resolveEntity(s.Set, "")
+		if err != nil {
+			return nil, err
+		}
+		domain, _, _ := strings.Cut(id, ".")
+		if !helperDomains[domain] {
+			return nil, fmt.Errorf("set only supports helper entities (%s), got %s", strings.Join(helperDomainNames(), ", "), id)
+		}
+		if s.Value == nil {
+			return nil, fmt.Errorf("set %s needs a value", id)
+		}
+		value := fmt.Sprint(s.Value)
+		a.label = "set " + id
+		a.entities = []string{id}
+		a.run = func(sess *client.Session) ([]client.State, error) {
+			current, err := getClient().GetState(id)
+			if err != nil {
+				return nil, err
+			}
+			service, data, err := helperServiceCall(current, value)
+			if err != nil {
+				return nil, err
+			}
+			data["entity_id"] = id
+			return callAndSettle(sess, domain, service, data, a.entities, defaultSettleWait)
+		}
+
+	case s.Wait != "":
+		id, err := resolveEntity(s.Wait, "")
+		if err != nil {
+			return nil, err
+		}
+		var attrs []string
+		for k, v := range s.Attr {
+			attrs = append(attrs, fmt.Sprintf("%s=%v", k, v))
+		}
+		cond, err := newWaitCondition(s.State, attrs, s.Where)
+		if err != nil {
+			return nil, err
+		}
+		timeout, err := parseStepDuration("timeout", s.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		hold, err := parseStepDuration("for", s.For)
+		if err != nil {
+			return nil, err
+		}
+		a.label = "wait " + id
+		a.run = func(sess *client.Session) ([]client.State, error) {
+			st, matched, err := waitForState(sess, id, cond, hold, timeout)
+			if err != nil {
+				return nil, err
+			}
+			if !matched {
+				current := "unknown"
+				if st != nil {
+					current = st.State
+				}
+				return nil, fmt.Errorf("timed out after %s (state: %s)", timeout, current)
+			}
+			return []client.State{*st}, nil
+		}
+
+	case s.Sleep != "":
+		d, err := parseStepDuration("sleep", s.Sleep)
+		if err != nil {
+			return nil, err
+		}
+		a.label = "sleep " + d.String()
+		a.run = func(*client.Session) ([]client.State, error) {
+			time.Sleep(d)
+			return nil, nil
+		}
+
+	default:
+		if len(s.Parallel) == 0 {
+			return nil, fmt.Errorf("parallel group is empty")
+		}
+		children, err := compileBatchSteps(s.Parallel, pos)
+		if err != nil {
+			return nil, err
+		}
+		a.label = fmt.Sprintf("parallel (%d step%s)", len(children), plural(len(children)))
+		a.children = children
+	}
+	return a, nil
+}
+
+// compileServiceStep applies service call's checks to a service step.
+func compileServiceStep(a *batchAction, s batchStep) error {
+	domain, svc, found := strings.Cut(s.Service, ".")
+	if !found || domain == "" || svc == "" {
+		return fmt.Errorf("service must be in format domain.service, got: %s", s.Service)
+	}
+	if restrictedServices[s.Service] && entityFilter.Mode() == "exposed" {
+		return fmt.Errorf("service %s is not permitted in exposed mode", s.Service)
+	}
+	if len(s.Entity) == 0 && entityRequiredServiceDomains[domain] {
+		return fmt.Errorf("service %s requires an entity", s.Service)
+	}
+
+	resolveDomain := domain
+	if domain == "homeassistant" {
+		resolveDomain = ""
+	}
+	entities, err := resolveServiceEntities(s.Entity, resolveDomain)
+	if err != nil {
+		return err
+	}
+	for _, e := range entities {
+		if err := checkServiceDomain(domain, svc, e); err != nil {
+			return err
+		}
+	}
+
+	data := map[string]any{}
+	for k, v := range s.Data {
+		if targetKeys[k] && entityFilter.Mode() == "exposed" {
+			return fmt.Errorf("%s cannot be set in data in exposed mode; use entity", k)
+		}
+		data[k] = v
+	}
+	switch len(entities) {
+	case 0:
+	case 1:
+		data["entity_id"] = entities[0]
+	default:
+		data["entity_id"] = entities
+	}
+	if err := checkServiceStepData(a.step, domain, svc, s.Data, data, entities); err != nil {
+		return err
+	}
+
+	a.label = "service " + s.Service
+	a.entities = entities
+	a.run = func(sess *client.Session) ([]client.State, error) {
+		if len(entities) == 0 {
+			_, err := sess.CallService(domain, svc, data)
+			return nil, err
+		}
+		return callAndSettle(sess, domain, svc, data, entities, defaultSettleWait)
+	}
+	return nil
+}
+
+// checkServiceStepData makes service call's payload checks on a service
+// step: its data against the service's declared fields, and colors and
+// color temperatures against every targeted light. As with service call,
+// a step without data has nothing to check against the schema, and when
+// the schema cannot be fetched the step goes ahead unchecked.
+func checkServiceStepData(pos, domain, svc string, userData, data map[string]any, entities []string) error {
+	if len(userData) > 0 {
+		schema, err := getClient().DescribeService(domain, svc)
+		switch {
+		case errors.Is(err, client.ErrServiceNotFound):
+			return err
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "warning: step %s: skipping payload validation: %s\n", pos, err)
+		default:
+			if err := validateServiceData(schema, userData, data); err != nil {
+				return err
+			}
+		}
+	}
+	if (domain == "light" || domain == "homeassistant") && len(entities) > 0 {
+		states, err := filteredStates()
+		if err != nil {
+			return err
+		}
+		var targeted []client.State
+		for _, s := range states {
+			if containsString(entities, s.EntityID) {
+				targeted = append(targeted, s)
+			}
+		}
+		if err := checkLightCapabilities(targeted, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseStepDuration parses an optional duration field of a step.
+func parseStepDuration(field, s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid %s duration %q", field, s)
+	}
+	return d, nil
+}
+
+// touchedEntities returns every entity the actions change, for rollback.
+func touchedEntities(actions []*batchAction) []string {
+	var ids []string
+	add := func(list []string) {
+		for _, id := range list {
+			if !containsString(ids, id) {
+				ids = append(ids, id)
+			}
+		}
+	}
+	for _, a := range actions {
+		add(a.entities)
+		add(touchedEntities(a.children))
+	}
+	return ids
+}
+
+// batchResult is the outcome of a batch run.
+type batchResult struct {
+	OK            bool         `json:"ok"`
+	Steps         []stepResult `json:"steps"`
+	RolledBack    bool         `json:"rolled_back,omitempty"`
+	RollbackError string       `json:"rollback_error,omitempty"`
+}
+
+// stepResult is the outcome of one step. Steps of a parallel group are
+// nested under it.
+type stepResult struct {
+	Step     string         `json:"step"`
+	Name     string         `json:"name,omitempty"`
+	Action   string         `json:"action"`
+	OK       bool           `json:"ok"`
+	Skipped  bool           `json:"skipped,omitempty"`
+	Error    string         `json:"error,omitempty"`
+	Duration string         `json:"duration,omitempty"`
+	States   []client.State `json:"states,omitempty"`
+	Steps    []stepResult   `json:"steps,omitempty"`
+}
+
+// errStepsFailed marks a parallel group in which some step failed.
+var errStepsFailed = errors.New("one or more steps failed")
+
+// runBatch runs actions in order. After a failure, the remaining steps are
+// reported as skipped unless continueOnError is set.
+func runBatch(sess *client.Session, actions []*batchAction, continueOnError bool) batchResult {
+	result := batchResult{OK: true}
+	for _, a := range actions {
+		if !result.OK && !continueOnError {
+			result.Steps = append(result.Steps, skippedResult(a))
+			continue
+		}
+		r := runAction(sess, a)
+		result.OK = result.OK && r.OK
+		result.Steps = append(result.Steps, r)
+	}
+	return result
+}
+
+func runAction(sess *client.Session, a *batchAction) stepResult {
+	r := stepResult{Step: a.step, Name: a.name, Action: a.label}
+	start := time.Now()
+	var err error
+	if a.children != nil {
+		r.Steps = make([]stepResult, len(a.children))
+		var wg sync.WaitGroup
+		for i, child := range a.children {
+			wg.Add(1)
+			go func(i int, child *batchAction) {
+				defer wg.Done()
+				r.Steps[i] = runAction(sess, child)
+			}(i, child)
+		}
+		wg.Wait()
+		for _, c := range r.Steps {
+			if !c.OK {
+				err = errStepsFailed
+			}
+		}
+	} else {
+		r.States, err = a.run(sess)
+	}
+	r.Duration = time.Since(start).Round(time.Millisecond).String()
+	r.OK = err == nil
+	if err != nil {
+		r.Error = err.Error()
+	}
+	return r
+}
+
+func skippedResult(a *batchAction) stepResult {
+	r := stepResult{Step: a.step, Name: a.name, Action: a.label, Skipped: true}
+	for _, c := range a.children {
+		r.Steps = append(r.Steps, skippedResult(c))
+	}
+	return r
+}
+
+// restoreStates puts entities back to the given states with scene.apply.
+func restoreStates(sess *client.Session, states map[string]*client.State) error {
+	entities := map[string]any{}
+	for id, s := range states {
+		if s == nil {
+			continue
+		}
+		target := map[string]any{"state": s.State}
+		for k, v := range s.Attributes {
+			target[k] = v
+		}
+		entities[id] = target
+	}
+	if len(entities) == 0 {
+		return nil
+	}
+	_, err := sess.CallService("scene", "apply", map[string]any{"entities": entities})
+	return err
+}
+
+// formatBatchPlain renders results one line per step, e.g.
+// "2 ok   service light.turn_on (312ms)".
+func formatBatchPlain(r batchResult) []string {
+	var lines []string
+	var walk func(steps []stepResult, indent string)
+	walk = func(steps []stepResult, indent string) {
+		for _, s := range steps {
+			status := "ok  "
+			switch {
+			case s.Skipped:
+				status = "skip"
+			case !s.OK:
+				status = "FAIL"
+			}
+			line := fmt.Sprintf("%s%s %s %s", indent, s.Step, status, s.Action)
+			if s.Name != "" {
+				line += " [" + s.Name + "]"
+			}
+			if s.Duration != "" {
+				line += " (" + s.Duration + ")"
+			}
+			if s.Error != "" && s.Error != errStepsFailed.Error() {
+				line += ": " + s.Error
+			}
+			lines = append(lines, line)
+			walk(s.Steps, indent+"  ")
+		}
+	}
+	walk(r.Steps, "")
+	switch {
+	case r.RolledBack:
+		lines = append(lines, "rolled back")
+	case r.RollbackError != "":
+		lines = append(lines, "rollback failed: "+r.RollbackError)
+	}
+	return lines
+}
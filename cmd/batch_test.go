@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/joaobarroca93/hactl/client"
+	"github.com/joaobarroca93/hactl/filter"
+)
+
+func TestParseBatchFile(t *testing.T) {
+	mapping := `
+steps:
+  - service: light.turn_on
+    entity: light.bedroom
+    data: {brightness_pct: 60}
+  - parallel:
+      - sleep: 1s
+      - set: input_number.target
+        value: 21.5
+`
+	steps, err := parseBatchFile([]byte(mapping))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(steps) != 2 || steps[0].Entity[0] != "light.bedroom" || len(steps[1].Parallel) != 2 {
+		t.Fatalf("unexpected steps: %+v", steps)
+	}
+
+	list := "- sleep: 1s\n- wait: cover.garage\n  state: [open, opening]\n"
+	steps, err = parseBatchFile([]byte(list))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(steps) != 2 || strings.Join(steps[1].State, ",") != "open,opening" {
+		t.Fatalf("unexpected steps: %+v", steps)
+	}
+
+	for _, bad := range []string{"steps:\n  - servce: light.turn_on\n", "steps: []\n", "not yaml: ["} {
+		if _, err := parseBatchFile([]byte(bad)); err == nil {
+			t.Errorf("parseBatchFile(%q) should fail", bad)
+		}
+	}
+}
+
+func TestCompileBatchSteps(t *testing.T) {
+	useFilter(t, filter.New("all", true))
+	useResolverStates(t, resolverFixture())
+
+	actions, err := compileBatchSteps([]batchStep{
+		{Service: "light.turn_off", Entity: stringList{"bedroom lamp", "cabinets"}},
+		{Parallel: []batchStep{
+			{Sleep: "10ms"},
+			{Service: "switch.turn_on", Entity: stringList{"switch.kitchen_light"}},
+		}},
+	}, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if actions[0].label != "service light.turn_off" || actions[1].children[1].step != "2.2" {
+		t.Errorf("unexpected actions: %+v %+v", actions[0], actions[1].children[1])
+	}
+	got := strings.Join(touchedEntities(actions), ",")
+	if got != "light.bedroom,light.kitchen_cabinets,switch.kitchen_light" {
+		t.Errorf("touchedEntities() = %q", got)
+	}
+
+	tests := []struct {
+		step batchStep
+		want string
+	}{
+		{batchStep{}, "give exactly one of"},
+		{batchStep{Service: "light.turn_on", Sleep: "1s"}, "give exactly one of"},
+		{batchStep{Service: "light.turn_on"}, "requires an entity"},
+		{batchStep{Service: "light.turn_on", Entity: stringList{"switch.kitchen_light"}}, "domain mismatch"},
+		{batchStep{Service: "light.turn_on", Entity: stringList{"garage door"}}, "entity not found"},
+		{batchStep{Set: "light.bedroom", Value: "on"}, "only supports helper entities"},
+		{batchStep{Wait: "light.bedroom"}, "nothing to wait for"},
+		{batchStep{Wait: "light.bedroom", State: stringList{"on"}, Timeout: "soon"}, "invalid timeout duration"},
+		{batchStep{Sleep: "-1s"}, "invalid sleep duration"},
+		{batchStep{Parallel: []batchStep{{Sleep: "x"}}}, "step 2.1: invalid sleep duration"},
+	}
+	for _, tt := range tests {
+		_, err := compileBatchSteps([]batchStep{{Sleep: "1ms"}, tt.step}, "")
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("step %+v: got %v, want %q", tt.step, err, tt.want)
+		}
+	}
+}
+
+func TestCompileBatchStepsChecksData(t *testing.T) {
+	f := newFakeHA(t)
+	f.services = `[{"domain": "light", "services": {"turn_on": {"fields": {
+		"brightness_pct": {"selector": {"number": {"min": 0, "max": 100}}},
+		"rgb_color": {"selector": {"color_rgb": {}}}
+	}}}}]`
+	useFilter(t, filter.New("all", true))
+	useResolverStates(t, []client.State{
+		{EntityID: "light.desk", Attributes: map[string]any{"supported_color_modes": []any{"brightness"}}},
+	})
+
+	tests := []struct {
+		step batchStep
+		want string
+	}{
+		{batchStep{Service: "light.turn_on", Entity: stringList{"light.desk"}, Data: map[string]any{"brightness_pct": 60}}, ""},
+		{batchStep{Service: "light.turn_on", Entity: stringList{"light.desk"}, Data: map[string]any{"brightnes_pct": 60}}, `step 2: unknown field "brightnes_pct"`},
+		{batchStep{Service: "light.turn_on", Entity: stringList{"light.desk"}, Data: map[string]any{"brightness_pct": 160}}, "step 2: invalid value for brightness_pct"},
+		{batchStep{Service: "light.turn_on", Entity: stringList{"light.desk"}, Data: map[string]any{"rgb_color": []any{255, 0, 0}}}, "step 2: light.desk"},
+		{batchStep{Service: "light.blink", Entity: stringList{"light.desk"}, Data: map[string]any{"times": 3}}, "step 2: service not found"},
+	}
+	for _, tt := range tests {
+		_, err := compileBatchSteps([]batchStep{{Sleep: "1ms"}, tt.step}, "")
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("data %v: unexpected error: %v", tt.step.Data, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("data %v: got %v, want %q", tt.step.Data, err, tt.want)
+		}
+	}
+}
+
+func TestRunBatch(t *testing.T) {
+	ok := func(step string) *batchAction {
+		return &batchAction{step: step, label: "ok", run: func(*client.Session) ([]client.State, error) {
+			return []client.State{{EntityID: "light.a", State: "on"}}, nil
+		}}
+	}
+	fail := func(step string) *batchAction {
+		return &batchAction{step: step, label: "fail", run: func(*client.Session) ([]client.State, error) {
+			return nil, errors.New("boom")
+		}}
+	}
+	actions := []*batchAction{
+		ok("1"),
+		{step: "2", label: "parallel", children: []*batchAction{ok("2.1"), fail("2.2")}},
+		ok("3"),
+	}
+
+	r := runBatch(nil, actions, false)
+	if r.OK || !r.Steps[0].OK || r.Steps[1].OK || r.Steps[1].Steps[1].Error != "boom" || !r.Steps[2].Skipped {
+		t.Errorf("stop on error: unexpected result %+v", r)
+	}
+
+	r = runBatch(nil, actions, true)
+	if r.OK || r.Steps[2].Skipped || !r.Steps[2].OK {
+		t.Errorf("continue on error: unexpected result %+v", r)
+	}
+
+	lines := formatBatchPlain(batchResult{Steps: []stepResult{
+		{Step: "1", Action: "service light.turn_on", OK: true, Duration: "12ms"},
+		{Step: "2", Action: "parallel (1 step)", Error: errStepsFailed.Error(), Steps: []stepResult{
+			{Step: "2.1", Action: "wait cover.garage", Name: "garage", Error: "timed out"},
+		}},
+		{Step: "3", Action: "sleep 1s", Skipped: true},
+	}, RolledBack: true})
+	want := "1 ok   service light.turn_on (12ms)\n" +
+		"2 FAIL parallel (1 step)\n" +
+		"  2.1 FAIL wait cover.garage [garage]: timed out\n" +
+		"3 skip sleep 1s\n" +
+		"rolled back"
+	if got := strings.Join(lines, "\n"); got != want {
+		t.Errorf("formatBatchPlain() =\n%s\nwant\n%s", got, want)
+	}
+}
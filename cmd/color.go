@@ -0,0 +1,250 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/joaobarroca93/hactl/client"
+)
+
+// cssColors maps the CSS named colors to their RGB values.
+var cssColors = map[string][3]int{
+	"aliceblue": {240, 248, 255}, "antiquewhite": {250, 235, 215}, "aqua": {0, 255, 255},
+	"aquamarine": {127, 255, 212}, "azure": {240, 255, 255}, "beige": {245, 245, 220},
+	"bisque": {255, 228, 196}, "black": {0, 0, 0}, "blanchedalmond": {255, 235, 205},
+	"blue": {0, 0, 255}, "blueviolet": {138, 43, 226}, "brown": {165, 42, 42},
+	"burlywood": {222, 184, 135}, "cadetblue": {95, 158, 160}, "chartreuse": {127, 255, 0},
+	"chocolate": {210, 105, 30}, "coral": {255, 127, 80}, "cornflowerblue": {100, 149, 237},
+	"cornsilk": {255, 248, 220}, "crimson": {220, 20, 60}, "cyan": {0, 255, 255},
+	"darkblue": {0, 0, 139}, "darkcyan": {0, 139, 139}, "darkgoldenrod": {184, 134, 11},
+	"darkgray": {169, 169, 169}, "darkgreen": {0, 100, 0}, "darkgrey": {169, 169, 169},
+	"darkkhaki": {189, 183, 107}, "darkmagenta": {139, 0, 139}, "darkolivegreen": {85, 107, 47},
+	"darkorange": {255, 140, 0}, "darkorchid": {153, 50, 204}, "darkred": {139, 0, 0},
+	"darksalmon": {233, 150, 122}, "darkseagreen": {143, 188, 143}, "darkslateblue": {72, 61, 139},
+	"darkslategray": {47, 79, 79}, "darkslategrey": {47, 79, 79}, "darkturquoise": {0, 206, 209},
+	"darkviolet": {148, 0, 211}, "deeppink": {255, 20, 147}, "deepskyblue": {0, 191, 255},
+	"dimgray": {105, 105, 105}, "dimgrey": {105, 105, 105}, "dodgerblue": {30, 144, 255},
+	"firebrick": {178, 34, 34}, "floralwhite": {255, 250, 240}, "forestgreen": {34, 139, 34},
+	"fuchsia": {255, 0, 255}, "gainsboro": {220, 220, 220}, "ghostwhite": {248, 248, 255},
+	"gold": {255, 215, 0}, "goldenrod": {218, 165, 32}, "gray": {128, 128, 128},
+	"green": {0, 128, 0}, "greenyellow": {173, 255, 47}, "grey": {128, 128, 128},
+	"honeydew": {240, 255, 240}, "hotpink": {255, 105, 180}, "indianred": {205, 92, 92},
+	"indigo": {75, 0, 130}, "ivory": {255, 255, 240}, "khaki": {240, 230, 140},
+	"lavender": {230, 230, 250}, "lavenderblush": {255, 240, 245}, "lawngreen": {124, 252, 0},
+	"lemonchiffon": {255, 250, 205}, "lightblue": {173, 216, 230}, "lightcoral": {240, 128, 128},
+	"lightcyan": {224, 255, 255}, "lightgoldenrodyellow": {250, 250, 210}, "lightgray": {211, 211, 211},
+	"lightgreen": {144, 238, 144}, "lightgrey": {211, 211, 211}, "lightpink": {255, 182, 193},
+	"lightsalmon": {255, 160, 122}, "lightseagreen": {32, 178, 170}, "lightskyblue": {135, 206, 250},
+	"lightslategray": {119, 136, 153}, "lightslategrey": {119, 136, 153}, "lightsteelblue": {176, 196, 222},
+	"lightyellow": {255, 255, 224}, "lime": {0, 255, 0}, "limegreen": {50, 205, 50},
+	"linen": {250, 240, 230}, "magenta": {255, 0, 255}, "maroon": {128, 0, 0},
+	"mediumaquamarine": {102, 205, 170}, "mediumblue": {0, 0, 205}, "mediumorchid": {186, 85, 211},
+	"mediumpurple": {147, 112, 219}, "mediumseagreen": {60, 179, 113}, "mediumslateblue": {123, 104, 238},
+	"mediumspringgreen": {0, 250, 154}, "mediumturquoise": {72, 209, 204}, "mediumvioletred": {199, 21, 133},
+	"midnightblue": {25, 25, 112}, "mintcream": {245, 255, 250}, "mistyrose": {255, 228, 225},
+	"moccasin": {255, 228, 181}, "navajowhite": {255, 222, 173}, "navy": {0, 0, 128},
+	"oldlace": {253, 245, 230}, "olive": {128, 128, 0}, "olivedrab": {107, 142, 35},
+	"orange": {255, 165, 0}, "orangered": {255, 69, 0}, "orchid": {218, 112, 214},
+	"palegoldenrod": {238, 232, 170}, "palegreen": {152, 251, 152}, "paleturquoise": {175, 238, 238},
+	"palevioletred": {219, 112, 147}, "papayawhip": {255, 239, 213}, "peachpuff": {255, 218, 185},
+	"peru": {205, 133, 63}, "pink": {255, 192, 203}, "plum": {221, 160, 221},
+	"powderblue": {176, 224, 230}, "purple": {128, 0, 128}, "rebeccapurple": {102, 51, 153},
+	"red": {255, 0, 0}, "rosybrown": {188, 143, 143}, "royalblue": {65, 105, 225},
+	"saddlebrown": {139, 69, 19}, "salmon": {250, 128, 114}, "sandybrown": {244, 164, 96},
+	"seagreen": {46, 139, 87}, "seashell": {255, 245, 238}, "sienna": {160, 82, 45},
+	"silver": {192, 192, 192}, "skyblue": {135, 206, 235}, "slateblue": {106, 90, 205},
+	"slategray": {112, 128, 144}, "slategrey": {112, 128, 144}, "snow": {255, 250, 250},
+	"springgreen": {0, 255, 127}, "steelblue": {70, 130, 180}, "tan": {210, 180, 140},
+	"teal": {0, 128, 128}, "thistle": {216, 191, 216}, "tomato": {255, 99, 71},
+	"turquoise": {64, 224, 208}, "violet": {238, 130, 238}, "wheat": {245, 222, 179},
+	"white": {255, 255, 255}, "whitesmoke": {245, 245, 245}, "yellow": {255, 255, 0},
+	"yellowgreen": {154, 205, 50},
+}
+
+// colorModes are the supported_color_modes that take a color (rgb_color,
+// hs_color or xy_color). Home Assistant converts between them.
+var colorModes = []string{"hs", "xy", "rgb", "rgbw", "rgbww"}
+
+// parseColor converts a CSS color name, #rrggbb or #rgb hex, rgb(r,g,b) or
+// hsv(h,s,v) to an rgb_color list.
+func parseColor(s string) ([]int, error) {
+	raw := strings.ToLower(strings.TrimSpace(s))
+	name := strings.NewReplacer(" ", "", "_", "", "-", "").Replace(raw)
+	if rgb, ok := cssColors[name]; ok {
+		return rgb[:], nil
+	}
+	switch {
+	case strings.HasPrefix(raw, "#"):
+		return parseHexColor(raw[1:])
+	case strings.HasPrefix(raw, "rgb(") && strings.HasSuffix(raw, ")"):
+		return parseRGBTriplet(raw[4 : len(raw)-1])
+	case strings.HasPrefix(raw, "hsv(") && strings.HasSuffix(raw, ")"):
+		return parseHSV(raw[4 : len(raw)-1])
+	}
+	return nil, fmt.Errorf("unknown color %q: use a CSS color name, #rrggbb, rgb(r,g,b) or hsv(h,s,v)", s)
+}
+
+func parseHexColor(hex string) ([]int, error) {
+	full := hex
+	if len(hex) == 3 {
+		full = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	n, err := strconv.ParseUint(full, 16, 32)
+	if len(full) != 6 || err != nil {
+		return nil, fmt.Errorf("invalid hex color #%s: use #rrggbb or #rgb", hex)
+	}
+	return []int{int(n >> 16), int(n >> 8 & 0xff), int(n & 0xff)}, nil
+}
+
+// parseRGBTriplet parses "r,g,b" with each component in 0-255.
+func parseRGBTriplet(s string) ([]int, error) {
+	nums, err := parseTriplet(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RGB color %q: use R,G,B with values 0-255", s)
+	}
+	rgb := make([]int, 3)
+	for i, n := range nums {
+		if n < 0 || n > 255 || n != math.Trunc(n) {
+			return nil, fmt.Errorf("invalid RGB color %q: use R,G,B with values 0-255", s)
+		}
+		rgb[i] = int(n)
+	}
+	return rgb, nil
+}
+
+// parseHSV parses "h,s,v" (hue 0-360, saturation and value 0-100) and
+// converts it to RGB.
+func parseHSV(s string) ([]int, error) {
+	nums, err := parseTriplet(s)
+	if err != nil || nums[0] < 0 || nums[0] > 360 || nums[1] < 0 || nums[1] > 100 || nums[2] < 0 || nums[2] > 100 {
+		return nil, fmt.Errorf("invalid HSV color %q: use hsv(h,s,v) with hue 0-360 and saturation and value 0-100", s)
+	}
+	return hsvToRGB(nums[0], nums[1]/100, nums[2]/100), nil
+}
+
+func parseTriplet(s string) ([]float64, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("expected 3 values")
+	}
+	nums := make([]float64, 3)
+	for i, p := range parts {
+		n, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return nil, err
+		}
+		nums[i] = n
+	}
+	return nums, nil
+}
+
+// hsvToRGB converts hue in degrees and saturation and value in 0-1.
+func hsvToRGB(h, s, v float64) []int {
+	h = math.Mod(h, 360) / 60
+	c := v * s
+	x := c * (1 - math.Abs(math.Mod(h, 2)-1))
+	var r, g, b float64
+	switch int(h) {
+	case 0:
+		r, g, b = c, x, 0
+	case 1:
+		r, g, b = x, c, 0
+	case 2:
+		r, g, b = 0, c, x
+	case 3:
+		r, g, b = 0, x, c
+	case 4:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+	m := v - c
+	return []int{
+		int(math.Round((r + m) * 255)),
+		int(math.Round((g + m) * 255)),
+		int(math.Round((b + m) * 255)),
+	}
+}
+
+// checkLightCapabilities checks the color payload keys against what each
+// targeted light reports it can do: a color needs a color mode, and a color
+// temperature must lie within the light's kelvin range. Lights that do not
+// report supported_color_modes (e.g. unavailable ones) are not checked.
+func checkLightCapabilities(states []client.State, data map[string]any) error {
+	wantsColor := hasAnyKey(data, "rgb_color", "hs_color", "xy_color", "rgbw_color", "rgbww_color")
+	kelvin, hasKelvin := requestedKelvin(data)
+	if !wantsColor && !hasKelvin {
+		return nil
+	}
+	for _, s := range states {
+		if !strings.HasPrefix(s.EntityID, "light.") {
+			continue
+		}
+		modes := stringAttrList(s.Attributes["supported_color_modes"])
+		if len(modes) == 0 {
+			continue
+		}
+		hasColor := false
+		for _, m := range colorModes {
+			if containsString(modes, m) {
+				hasColor = true
+			}
+		}
+		if wantsColor && !hasColor {
+			return fmt.Errorf("%s does not support color (supported_color_modes: %s)", s.EntityID, strings.Join(modes, ", "))
+		}
+		if !hasKelvin {
+			continue
+		}
+		if !containsString(modes, "color_temp") {
+			// Home Assistant emulates color temperature on color lights.
+			if !hasColor {
+				return fmt.Errorf("%s does not support color temperature (supported_color_modes: %s)", s.EntityID, strings.Join(modes, ", "))
+			}
+			continue
+		}
+		lo, okLo := toFloat(s.Attributes["min_color_temp_kelvin"])
+		hi, okHi := toFloat(s.Attributes["max_color_temp_kelvin"])
+		if okLo && okHi && (kelvin < lo || kelvin > hi) {
+			return fmt.Errorf("%s supports %s-%s K, got: %s K", s.EntityID, formatNumber(lo), formatNumber(hi), formatNumber(math.Round(kelvin)))
+		}
+	}
+	return nil
+}
+
+// requestedKelvin returns the color temperature of the payload in kelvin,
+// converting color_temp from mireds.
+func requestedKelvin(data map[string]any) (float64, bool) {
+	if k, ok := toFloat(data["color_temp_kelvin"]); ok {
+		return k, true
+	}
+	if m, ok := toFloat(data["color_temp"]); ok && m > 0 {
+		return 1e6 / m, true
+	}
+	return 0, false
+}
+
+func hasAnyKey(data map[string]any, keys ...string) bool {
+	for _, k := range keys {
+		if _, ok := data[k]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// stringAttrList returns a list attribute as sorted strings.
+func stringAttrList(v any) []string {
+	list, _ := v.([]any)
+	out := make([]string, 0, len(list))
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
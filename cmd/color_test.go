@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/joaobarroca93/hactl/client"
+)
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []int
+	}{
+		{"tomato", []int{255, 99, 71}},
+		{"Dark Orange", []int{255, 140, 0}},
+		{"rebecca_purple", []int{102, 51, 153}},
+		{"#FF8800", []int{255, 136, 0}},
+		{"#f80", []int{255, 136, 0}},
+		{"rgb(10, 20, 30)", []int{10, 20, 30}},
+		{"hsv(0,100,100)", []int{255, 0, 0}},
+		{"hsv(120,100,50)", []int{0, 128, 0}},
+		{"hsv(240,50,100)", []int{128, 128, 255}},
+		{"hsv(360,0,100)", []int{255, 255, 255}},
+	}
+	for _, tt := range tests {
+		got, err := parseColor(tt.in)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseColor(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseColorErrors(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"blurple", "unknown color"},
+		{"#12345", "invalid hex color #12345"},
+		{"#ggg", "invalid hex color"},
+		{"rgb(256,0,0)", "invalid RGB color"},
+		{"rgb(1,2)", "invalid RGB color"},
+		{"hsv(400,50,50)", "invalid HSV color"},
+		{"hsv(10,150,50)", "invalid HSV color"},
+	}
+	for _, tt := range tests {
+		if _, err := parseColor(tt.in); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parseColor(%q): got %v, want %q", tt.in, err, tt.want)
+		}
+	}
+}
+
+func TestCheckLightCapabilities(t *testing.T) {
+	light := func(id string, attrs map[string]any) client.State {
+		return client.State{EntityID: id, State: "on", Attributes: attrs}
+	}
+	rgbLight := light("light.strip", map[string]any{"supported_color_modes": []any{"rgb"}})
+	ctLight := light("light.desk", map[string]any{
+		"supported_color_modes": []any{"color_temp", "xy"},
+		"min_color_temp_kelvin": 2000.0,
+		"max_color_temp_kelvin": 6500.0,
+	})
+	dimmer := light("light.hall", map[string]any{"supported_color_modes": []any{"brightness"}})
+	unavailable := light("light.garage", map[string]any{})
+
+	tests := []struct {
+		name   string
+		states []client.State
+		data   map[string]any
+		want   string // "" means no error
+	}{
+		{"no color keys", []client.State{dimmer}, map[string]any{"brightness": 100}, ""},
+		{"color on rgb light", []client.State{rgbLight}, map[string]any{"rgb_color": []int{1, 2, 3}}, ""},
+		{"color on dimmer", []client.State{ctLight, dimmer}, map[string]any{"rgb_color": []int{1, 2, 3}}, "light.hall does not support color (supported_color_modes: brightness)"},
+		{"kelvin in range", []client.State{ctLight}, map[string]any{"color_temp_kelvin": 2700}, ""},
+		{"kelvin out of range", []client.State{ctLight}, map[string]any{"color_temp_kelvin": 9000}, "light.desk supports 2000-6500 K, got: 9000 K"},
+		{"mireds out of range", []client.State{ctLight}, map[string]any{"color_temp": 600}, "got: 1667 K"},
+		{"kelvin emulated on rgb light", []client.State{rgbLight}, map[string]any{"color_temp_kelvin": 9000}, ""},
+		{"kelvin on dimmer", []client.State{dimmer}, map[string]any{"color_temp_kelvin": 2700}, "does not support color temperature"},
+		{"unknown capabilities", []client.State{unavailable}, map[string]any{"rgb_color": []int{1, 2, 3}}, ""},
+		{"not a light", []client.State{{EntityID: "switch.fan"}}, map[string]any{"rgb_color": []int{1, 2, 3}}, ""},
+	}
+	for _, tt := range tests {
+		err := checkLightCapabilities(tt.states, tt.data)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("%s: got %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
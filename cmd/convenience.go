@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// convenienceFlag is a service call flag that sets one payload key for the
+// services of some domains, e.g. --position for cover.set_cover_position.
+type convenienceFlag struct {
+	name    string
+	domains []string
+	key     string
+	kind    string // "int", "float" or "string"
+	usage   string
+
+	// min and max bound numeric values when max > min.
+	min, max float64
+	// choices restricts string values when non-empty.
+	choices []string
+	// group names flags that set alternatives of the same setting; at most
+	// one flag of a group may be given.
+	group string
+	// convert turns the parsed value into the payload value (unit
+	// conversion); nil keeps it as-is.
+	convert func(v any) (any, error)
+}
+
+// convenienceFlags is the registry of per-domain service call flags, applied
+// in this order.
+var convenienceFlags = []convenienceFlag{
+	{
+		name: "brightness", domains: []string{"light"}, key: "brightness", kind: "int",
+		usage: "brightness percentage (0-100)", min: 0, max: 100,
+		convert: func(v any) (any, error) { return int(float64(v.(int)) / 100 * 255), nil },
+	},
+	{
+		name: "color-temp", domains: []string{"light"}, key: "color_temp", kind: "int",
+		usage: "color temperature in mireds", group: "color",
+	},
+	{
+		name: "kelvin", domains: []string{"light"}, key: "color_temp_kelvin", kind: "int",
+		usage: "color temperature in kelvin (1000-40000)", min: 1000, max: 40000, group: "color",
+	},
+	{
+		name: "rgb", domains: []string{"light"}, key: "rgb_color", kind: "string",
+		usage: "RGB color as R,G,B (e.g. 255,128,0)", group: "color",
+		convert: func(v any) (any, error) { return parseRGBTriplet(v.(string)) },
+	},
+	{
+		name: "color", domains: []string{"light"}, key: "rgb_color", kind: "string",
+		usage: "color as a CSS name, #rrggbb, rgb(r,g,b) or hsv(h,s,v)", group: "color",
+		convert: func(v any) (any, error) { return parseColor(v.(string)) },
+	},
+	{
+		name: "transition", domains: []string{"light"}, key: "transition", kind: "float",
+		usage: "transition time in seconds", min: 0, max: 300,
+	},
+	{
+		name: "effect", domains: []string{"light"}, key: "effect", kind: "string",
+		usage: "light effect name (see the effect_list attribute)",
+	},
+	{
+		name: "temperature", domains: []string{"climate", "water_heater"}, key: "temperature", kind: "float",
+		usage: "target temperature",
+	},
+	{
+		name: "target-low", domains: []string{"climate"}, key: "target_temp_low", kind: "float",
+		usage: "lower target temperature in heat_cool mode",
+	},
+	{
+		name: "target-high", domains: []string{"climate"}, key: "target_temp_high", kind: "float",
+		usage: "upper target temperature in heat_cool mode",
+	},
+	{
+		name: "hvac-mode", domains: []string{"climate"}, key: "hvac_mode", kind: "string",
+		usage:   "HVAC mode (off, heat, cool, heat_cool, auto, dry, fan_only)",
+		choices: []string{"off", "heat", "cool", "heat_cool", "auto", "dry", "fan_only"},
+	},
+	{
+		name: "position", domains: []string{"cover"}, key: "position", kind: "int",
+		usage: "cover position percentage (0 closed - 100 open)", min: 0, max: 100,
+	},
+	{
+		name: "tilt", domains: []string{"cover"}, key: "tilt_position", kind: "int",
+		usage: "cover tilt percentage (0-100)", min: 0, max: 100,
+	},
+	{
+		name: "percentage", domains: []string{"fan"}, key: "percentage", kind: "int",
+		usage: "fan speed percentage (0-100)", min: 0, max: 100,
+	},
+	{
+		name: "preset", domains: []string{"fan", "climate"}, key: "preset_mode", kind: "string",
+		usage: "preset mode (see the preset_modes attribute)",
+	},
+	{
+		name: "volume", domains: []string{"media_player"}, key: "volume_level", kind: "float",
+		usage: "volume percentage (0-100)", min: 0, max: 100,
+		convert: func(v any) (any, error) { return v.(float64) / 100, nil },
+	},
+	{
+		name: "source", domains: []string{"media_player"}, key: "source", kind: "string",
+		usage: "input source (see the source_list attribute)",
+	},
+}
+
+// addConvenienceFlags registers every flag in the registry on cmd.
+func addConvenienceFlags(cmd *cobra.Command) {
+	for _, f := range convenienceFlags {
+		usage := fmt.Sprintf("%s, for %s services", f.usage, strings.Join(f.domains, "/"))
+		switch f.kind {
+		case "int":
+			cmd.Flags().Int(f.name, 0, usage)
+		case "float":
+			cmd.Flags().Float64(f.name, 0, usage)
+		default:
+			cmd.Flags().String(f.name, "", usage)
+		}
+	}
+}
+
+// convenienceFlagsSet reports whether any convenience flag is given on cmd.
+func convenienceFlagsSet(cmd *cobra.Command) bool {
+	for _, f := range convenienceFlags {
+		if cmd.Flags().Changed(f.name) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyConvenienceFlags sets the payload keys of the convenience flags given
+// on cmd. A flag used with a service outside its domains is an error, except
+// for homeassistant.* services, which forward data to every domain.
+func applyConvenienceFlags(cmd *cobra.Command, domain string, data map[string]any) error {
+	groups := map[string]string{}
+	for _, f := range convenienceFlags {
+		if !cmd.Flags().Changed(f.name) {
+			continue
+		}
+		if domain != "homeassistant" && !containsString(f.domains, domain) {
+			return fmt.Errorf("--%s applies to %s services, not %s", f.name, strings.Join(f.domains, "/"), domain)
+		}
+		if f.group != "" {
+			if other, ok := groups[f.group]; ok {
+				return fmt.Errorf("--%s and --%s cannot be combined: both set the %s", other, f.name, f.group)
+			}
+			groups[f.group] = f.name
+		}
+		v, err := f.value(cmd.Flags().Lookup(f.name).Value.String())
+		if err != nil {
+			return err
+		}
+		if v != nil {
+			data[f.key] = v
+		}
+	}
+	return nil
+}
+
+// value parses, validates and converts a raw flag value.
+func (f convenienceFlag) value(raw string) (any, error) {
+	var v any
+	switch f.kind {
+	case "int", "float":
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("--%s must be a number, got: %s", f.name, raw)
+		}
+		if f.max > f.min && (n < f.min || n > f.max) {
+			return nil, fmt.Errorf("--%s must be between %s and %s, got: %s", f.name, formatNumber(f.min), formatNumber(f.max), raw)
+		}
+		v = n
+		if f.kind == "int" {
+			v = int(n)
+		}
+	default:
+		if len(f.choices) > 0 && !containsString(f.choices, raw) {
+			return nil, fmt.Errorf("--%s must be one of: %s", f.name, strings.Join(f.choices, ", "))
+		}
+		v = raw
+	}
+	if f.convert != nil {
+		converted, err := f.convert(v)
+		if err != nil {
+			return nil, fmt.Errorf("--%s: %w", f.name, err)
+		}
+		return converted, nil
+	}
+	return v, nil
+}
+
+// convenienceHelp lists the convenience flags by domain, for command help.
+func convenienceHelp() string {
+	byDomain := map[string][]string{}
+	for _, f := range convenienceFlags {
+		for _, d := range f.domains {
+			byDomain[d] = append(byDomain[d], "--"+f.name)
+		}
+	}
+	domains := make([]string, 0, len(byDomain))
+	for d := range byDomain {
+		domains = append(domains, d)
+	}
+	sort.Strings(domains)
+	lines := make([]string, 0, len(domains))
+	for _, d := range domains {
+		lines = append(lines, fmt.Sprintf("  %-14s %s", d, strings.Join(byDomain[d], ", ")))
+	}
+	return strings.Join(lines, "\n")
+}
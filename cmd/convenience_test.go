@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func newConvenienceCmd(t *testing.T, flags map[string]string) *cobra.Command {
+	t.Helper()
+	cmd := &cobra.Command{}
+	addConvenienceFlags(cmd)
+	for k, v := range flags {
+		if err := cmd.Flags().Set(k, v); err != nil {
+			t.Fatalf("set --%s: %v", k, err)
+		}
+	}
+	return cmd
+}
+
+func TestApplyConvenienceFlags(t *testing.T) {
+	tests := []struct {
+		domain string
+		flags  map[string]string
+		want   map[string]any
+	}{
+		{"light", map[string]string{"brightness": "100"}, map[string]any{"brightness": 255}},
+		{"light", map[string]string{"kelvin": "2700", "transition": "1.5"}, map[string]any{"color_temp_kelvin": 2700, "transition": 1.5}},
+		{"light", map[string]string{"rgb": "255, 128,0"}, map[string]any{"rgb_color": []int{255, 128, 0}}},
+		{"light", map[string]string{"color": "#ff8000"}, map[string]any{"rgb_color": []int{255, 128, 0}}},
+		{"light", map[string]string{"effect": "colorloop"}, map[string]any{"effect": "colorloop"}},
+		{"cover", map[string]string{"position": "40", "tilt": "10"}, map[string]any{"position": 40, "tilt_position": 10}},
+		{"fan", map[string]string{"percentage": "33", "preset": "sleep"}, map[string]any{"percentage": 33, "preset_mode": "sleep"}},
+		{"media_player", map[string]string{"volume": "25", "source": "HDMI 1"}, map[string]any{"volume_level": 0.25, "source": "HDMI 1"}},
+		{"climate", map[string]string{"target-low": "19", "target-high": "23.5", "hvac-mode": "heat_cool"}, map[string]any{"target_temp_low": 19.0, "target_temp_high": 23.5, "hvac_mode": "heat_cool"}},
+		{"water_heater", map[string]string{"temperature": "55"}, map[string]any{"temperature": 55.0}},
+		{"homeassistant", map[string]string{"brightness": "0"}, map[string]any{"brightness": 0}},
+	}
+	for _, tt := range tests {
+		data := map[string]any{}
+		if err := applyConvenienceFlags(newConvenienceCmd(t, tt.flags), tt.domain, data); err != nil {
+			t.Errorf("%s %v: unexpected error: %v", tt.domain, tt.flags, err)
+			continue
+		}
+		if !reflect.DeepEqual(data, tt.want) {
+			t.Errorf("%s %v: got %#v, want %#v", tt.domain, tt.flags, data, tt.want)
+		}
+	}
+}
+
+func TestApplyConvenienceFlagsErrors(t *testing.T) {
+	tests := []struct {
+		domain string
+		flags  map[string]string
+		want   string
+	}{
+		{"switch", map[string]string{"brightness": "50"}, "--brightness applies to light services, not switch"},
+		{"light", map[string]string{"brightness": "150"}, "--brightness must be between 0 and 100, got: 150"},
+		{"cover", map[string]string{"position": "-1"}, "--position must be between 0 and 100"},
+		{"media_player", map[string]string{"volume": "101"}, "--volume must be between 0 and 100"},
+		{"light", map[string]string{"kelvin": "500"}, "--kelvin must be between 1000 and 40000"},
+		{"climate", map[string]string{"hvac-mode": "warm"}, "--hvac-mode must be one of"},
+		{"light", map[string]string{"rgb": "255,128"}, `--rgb: invalid RGB color "255,128"`},
+		{"light", map[string]string{"rgb": "red"}, "--rgb: invalid RGB color"},
+		{"light", map[string]string{"color": "blurple"}, "--color: unknown color"},
+		{"light", map[string]string{"rgb": "1,2,3", "color": "red"}, "--rgb and --color cannot be combined"},
+		{"light", map[string]string{"color-temp": "300", "kelvin": "2700"}, "--color-temp and --kelvin cannot be combined"},
+	}
+	for _, tt := range tests {
+		err := applyConvenienceFlags(newConvenienceCmd(t, tt.flags), tt.domain, map[string]any{})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s %v: got %v, want %q", tt.domain, tt.flags, err, tt.want)
+		}
+	}
+}
+
+func TestConvenienceHelp(t *testing.T) {
+	help := convenienceHelp()
+	for _, want := range []string{
+		"  cover          --position, --tilt",
+		"  climate        --temperature, --target-low, --target-high, --hvac-mode, --preset",
+		"  media_player   --volume, --source",
+	} {
+		if !strings.Contains(help, want) {
+			t.Errorf("help missing %q:\n%s", want, help)
+		}
+	}
+}
+
+func TestConvenienceFlagsSet(t *testing.T) {
+	if convenienceFlagsSet(newConvenienceCmd(t, nil)) {
+		t.Error("no flags given, want false")
+	}
+	if !convenienceFlagsSet(newConvenienceCmd(t, map[string]string{"brightness": "0"})) {
+		t.Error("--brightness 0 given, want true")
+	}
+}
@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// eventDebouncer implements events watch --debounce. The first event of an
+// entity opens a window; later events of the entity replace it, and the
+// last one is emitted when the window closes. The emitted event keeps the
+// old_state of the first, so it shows the whole change. Events that are not
+// about an entity pass straight through.
+type eventDebouncer struct {
+	window  time.Duration
+	pending map[string]*debouncedEvent
+}
+
+type debouncedEvent struct {
+	event map[string]any
+	due   time.Time
+}
+
+func newEventDebouncer(window time.Duration) *eventDebouncer {
+	return &eventDebouncer{window: window, pending: map[string]*debouncedEvent{}}
+}
+
+// add holds event back and returns nil, or returns it unchanged when it is
+// not about an entity.
+func (d *eventDebouncer) add(event map[string]any, now time.Time) map[string]any {
+	data, _ := event["data"].(map[string]any)
+	entityID, _ := data["entity_id"].(string)
+	if entityID == "" {
+		return event
+	}
+	p, ok := d.pending[entityID]
+	if !ok {
+		d.pending[entityID] = &debouncedEvent{event: event, due: now.Add(d.window)}
+		return nil
+	}
+	firstData, _ := p.event["data"].(map[string]any)
+	if oldState, ok := firstData["old_state"]; ok {
+		data["old_state"] = oldState
+	}
+	p.event = event
+	return nil
+}
+
+// next returns when the earliest window closes.
+func (d *eventDebouncer) next() (time.Time, bool) {
+	var next time.Time
+	for _, p := range d.pending {
+		if next.IsZero() || p.due.Before(next) {
+			next = p.due
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// due removes and returns the events whose window has closed by now, in
+// the order their windows closed.
+func (d *eventDebouncer) due(now time.Time) []map[string]any {
+	return d.take(func(p *debouncedEvent) bool { return !p.due.After(now) })
+}
+
+// flush removes and returns every held event.
+func (d *eventDebouncer) flush() []map[string]any {
+	return d.take(func(*debouncedEvent) bool { return true })
+}
+
+func (d *eventDebouncer) take(ready func(*debouncedEvent) bool) []map[string]any {
+	var out []*debouncedEvent
+	for id, p := range d.pending {
+		if ready(p) {
+			out = append(out, p)
+			delete(d.pending, id)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].due.Before(out[j].due) })
+	events := make([]map[string]any, len(out))
+	for i, p := range out {
+		events[i] = p.event
+	}
+	return events
+}
+
+// eventStats summarizes the events of one entity (or, for events that are
+// not about an entity, one event type) during an --aggregate window.
+type eventStats struct {
+	WindowStart time.Time `json:"window_start"`
+	WindowEnd   time.Time `json:"window_end"`
+	EntityID    string    `json:"entity_id,omitempty"`
+	EventType   string    `json:"event_type,omitempty"`
+	Count       int       `json:"count"`
+	Min         *float64  `json:"min,omitempty"`
+	Max         *float64  `json:"max,omitempty"`
+	Last        string    `json:"last,omitempty"`
+}
+
+// eventAggregator implements events watch --aggregate: it counts events per
+// entity over a window, tracking min and max of numeric states.
+type eventAggregator struct {
+	start time.Time
+	stats map[string]*eventStats
+	order []string
+}
+
+func newEventAggregator(start time.Time) *eventAggregator {
+	return &eventAggregator{start: start, stats: map[string]*eventStats{}}
+}
+
+func (a *eventAggregator) add(event map[string]any) {
+	data, _ := event["data"].(map[string]any)
+	entityID, _ := data["entity_id"].(string)
+	eventType, _ := event["event_type"].(string)
+
+	key := "entity:" + entityID
+	if entityID == "" {
+		key = "event:" + eventType
+	}
+	st, ok := a.stats[key]
+	if !ok {
+		st = &eventStats{EntityID: entityID}
+		if entityID == "" {
+			st.EventType = eventType
+		}
+		a.stats[key] = st
+		a.order = append(a.order, key)
+	}
+	st.Count++
+
+	newState, _ := data["new_state"].(map[string]any)
+	state, ok := newState["state"].(string)
+	if !ok {
+		return
+	}
+	st.Last = state
+	if n, err := strconv.ParseFloat(state, 64); err == nil {
+		if st.Min == nil || n < *st.Min {
+			st.Min = &n
+		}
+		if st.Max == nil || n > *st.Max {
+			st.Max = &n
+		}
+	}
+}
+
+// flush returns the stats of the window ending at end, in order of first
+// appearance, and starts the next window.
+func (a *eventAggregator) flush(end time.Time) []eventStats {
+	out := make([]eventStats, 0, len(a.order))
+	for _, key := range a.order {
+		st := *a.stats[key]
+		st.WindowStart, st.WindowEnd = a.start, end
+		out = append(out, st)
+	}
+	a.start = end
+	a.stats = map[string]*eventStats{}
+	a.order = nil
+	return out
+}
+
+// formatStatsPlain returns a one-line summary of an aggregate window.
+func formatStatsPlain(st eventStats) string {
+	name := st.EntityID
+	if name == "" {
+		name = st.EventType
+	}
+	parts := []string{fmt.Sprintf("%d event%s", st.Count, plural(st.Count))}
+	if st.Min != nil {
+		parts = append(parts, "min "+formatNumber(*st.Min), "max "+formatNumber(*st.Max))
+	}
+	if st.Last != "" {
+		parts = append(parts, "last "+st.Last)
+	}
+	return fmt.Sprintf("%s %s: %s", st.WindowEnd.Local().Format("15:04:05"), name, strings.Join(parts, ", "))
+}
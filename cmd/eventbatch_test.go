@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEventDebouncer(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	d := newEventDebouncer(2 * time.Second)
+
+	if d.add(stateChangedEvent("sensor.power", "10", "11", nil, nil), t0) != nil {
+		t.Fatal("first event should be held back")
+	}
+	d.add(stateChangedEvent("sensor.power", "11", "12", nil, nil), t0.Add(500*time.Millisecond))
+	d.add(stateChangedEvent("light.desk", "off", "on", nil, nil), t0.Add(time.Second))
+	d.add(stateChangedEvent("sensor.power", "12", "13", nil, nil), t0.Add(1500*time.Millisecond))
+	if e := d.add(map[string]any{"event_type": "backup_done"}, t0); e == nil {
+		t.Error("events without an entity should pass through")
+	}
+
+	if next, ok := d.next(); !ok || !next.Equal(t0.Add(2*time.Second)) {
+		t.Errorf("next() = %v, %v", next, ok)
+	}
+	if got := d.due(t0.Add(1900 * time.Millisecond)); len(got) != 0 {
+		t.Errorf("nothing should be due yet, got %d events", len(got))
+	}
+
+	got := d.due(t0.Add(2 * time.Second))
+	if len(got) != 1 {
+		t.Fatalf("due() returned %d events, want 1", len(got))
+	}
+	if line := formatEventPlain(got[0]); line != "sensor.power: 10 -> 13" {
+		t.Errorf("coalesced event = %q, want first old state and last new state", line)
+	}
+
+	rest := d.flush()
+	if len(rest) != 1 || formatEventPlain(rest[0]) != "light.desk: off -> on" {
+		t.Errorf("flush() = %v", rest)
+	}
+	if _, ok := d.next(); ok {
+		t.Error("debouncer should be empty after flush")
+	}
+}
+
+func TestEventAggregator(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	a := newEventAggregator(t0)
+	for _, s := range []string{"12.5", "3", "unavailable", "7"} {
+		a.add(stateChangedEvent("sensor.power", "", s, nil, nil))
+	}
+	a.add(stateChangedEvent("light.desk", "off", "on", nil, nil))
+	a.add(map[string]any{"event_type": "call_service", "data": map[string]any{}})
+	a.add(map[string]any{"event_type": "call_service", "data": map[string]any{}})
+
+	stats := a.flush(t0.Add(time.Minute))
+	if len(stats) != 3 {
+		t.Fatalf("flush() returned %d stats, want 3", len(stats))
+	}
+	power := stats[0]
+	if power.EntityID != "sensor.power" || power.Count != 4 || *power.Min != 3 || *power.Max != 12.5 || power.Last != "7" {
+		t.Errorf("sensor.power stats = %+v", power)
+	}
+	if !power.WindowStart.Equal(t0) || !power.WindowEnd.Equal(t0.Add(time.Minute)) {
+		t.Errorf("window = %v - %v", power.WindowStart, power.WindowEnd)
+	}
+	if stats[1].Min != nil || stats[1].Last != "on" {
+		t.Errorf("light.desk stats = %+v", stats[1])
+	}
+	if stats[2].EventType != "call_service" || stats[2].Count != 2 {
+		t.Errorf("call_service stats = %+v", stats[2])
+	}
+
+	next := a.flush(t0.Add(2 * time.Minute))
+	if len(next) != 0 || !a.start.Equal(t0.Add(2*time.Minute)) {
+		t.Errorf("second window = %+v, start %v", next, a.start)
+	}
+
+	if line := formatStatsPlain(power); line[9:] != "sensor.power: 4 events, min 3, max 12.5, last 7" {
+		t.Errorf("formatStatsPlain() = %q", line)
+	}
+}
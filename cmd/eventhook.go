@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// eventHooks reacts to watched events by running the --exec command and
+// POSTing to the --post URL, at most --max-concurrent at a time. When every
+// slot is busy, the watch waits for one to free up.
+type eventHooks struct {
+	command string
+	postURL string
+	http    *http.Client
+
+	sem chan struct{}
+	wg  sync.WaitGroup
+}
+
+// newEventHooks builds the hooks from the events watch flags. It returns nil
+// when neither --exec nor --post is given.
+func newEventHooks(cmd *cobra.Command) (*eventHooks, error) {
+	command, _ := cmd.Flags().GetString("exec")
+	postURL, _ := cmd.Flags().GetString("post")
+	maxConcurrent, _ := cmd.Flags().GetInt("max-concurrent")
+	if command == "" && postURL == "" {
+		return nil, nil
+	}
+	if postURL != "" {
+		u, err := url.Parse(postURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("--post must be an http or https URL, got: %s", postURL)
+		}
+	}
+	if maxConcurrent < 1 {
+		return nil, fmt.Errorf("--max-concurrent must be at least 1")
+	}
+	return &eventHooks{
+		command: command,
+		postURL: postURL,
+		http:    &http.Client{Timeout: 10 * time.Second},
+		sem:     make(chan struct{}, maxConcurrent),
+	}, nil
+}
+
+// handle starts the hooks for one event. Failures are reported on stderr and
+// do not stop the watch.
+func (h *eventHooks) handle(event map[string]any) {
+	if h == nil {
+		return
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	h.sem <- struct{}{}
+	h.wg.Add(1)
+	go func() {
+		defer func() {
+			<-h.sem
+			h.wg.Done()
+		}()
+		if h.command != "" {
+			if err := h.exec(event, body); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: --exec: %s\n", err)
+			}
+		}
+		if h.postURL != "" {
+			if err := h.post(body); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: --post: %s\n", err)
+			}
+		}
+	}()
+}
+
+// wait blocks until every running hook has finished.
+func (h *eventHooks) wait() {
+	if h != nil {
+		h.wg.Wait()
+	}
+}
+
+// exec runs the command through sh -c with the event as JSON on stdin and
+// its main fields in HACTL_* environment variables. Its output goes to
+// stderr: stdout carries the watched events, and concurrent commands
+// writing there would break the JSON lines.
+func (h *eventHooks) exec(event map[string]any, body []byte) error {
+	c := exec.Command("sh", "-c", h.command)
+	c.Env = append(os.Environ(), eventHookEnv(event)...)
+	c.Stdin = bytes.NewReader(body)
+	c.Stdout, c.Stderr = os.Stderr, os.Stderr
+	return c.Run()
+}
+
+// post sends the event as JSON to the --post URL.
+func (h *eventHooks) post(body []byte) error {
+	resp, err := h.http.Post(h.postURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s returned %s", h.postURL, resp.Status)
+	}
+	return nil
+}
+
+// eventHookEnv returns the HACTL_* variables describing an event. Variables
+// that do not apply to the event are set to the empty string.
+func eventHookEnv(event map[string]any) []string {
+	env := eventEnv(event)
+	str := func(key string) string {
+		if v, ok := env[key].(string); ok {
+			return v
+		}
+		return ""
+	}
+	fired, _ := event["time_fired"].(string)
+	return []string{
+		"HACTL_EVENT_TYPE=" + str("event_type"),
+		"HACTL_ENTITY=" + str("entity_id"),
+		"HACTL_DOMAIN=" + str("domain"),
+		"HACTL_OLD_STATE=" + str("old_state"),
+		"HACTL_NEW_STATE=" + str("new_state"),
+		"HACTL_TIME_FIRED=" + fired,
+	}
+}
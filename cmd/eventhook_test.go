@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestEventHookEnv(t *testing.T) {
+	event := stateChangedEvent("binary_sensor.door", "off", "on", nil, nil)
+	event["time_fired"] = "2024-01-05T07:30:00+00:00"
+	want := []string{
+		"HACTL_EVENT_TYPE=state_changed",
+		"HACTL_ENTITY=binary_sensor.door",
+		"HACTL_DOMAIN=binary_sensor",
+		"HACTL_OLD_STATE=off",
+		"HACTL_NEW_STATE=on",
+		"HACTL_TIME_FIRED=2024-01-05T07:30:00+00:00",
+	}
+	if got := eventHookEnv(event); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("eventHookEnv() = %v, want %v", got, want)
+	}
+
+	custom := eventHookEnv(map[string]any{"event_type": "backup_done"})
+	if custom[1] != "HACTL_ENTITY=" || custom[4] != "HACTL_NEW_STATE=" {
+		t.Errorf("missing fields should be empty, got %v", custom)
+	}
+}
+
+func TestEventHooksExec(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	h := &eventHooks{
+		command: `{ echo "$HACTL_ENTITY $HACTL_NEW_STATE"; cat; } > ` + out,
+		sem:     make(chan struct{}, 1),
+	}
+	h.handle(stateChangedEvent("light.desk", "off", "on", nil, nil))
+	h.wait()
+
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line, body, _ := strings.Cut(string(got), "\n")
+	if line != "light.desk on" {
+		t.Errorf("env line = %q", line)
+	}
+	var event map[string]any
+	if err := json.Unmarshal([]byte(body), &event); err != nil || event["event_type"] != "state_changed" {
+		t.Errorf("stdin = %q (%v)", body, err)
+	}
+}
+
+func TestEventHooksPost(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, r.Header.Get("Content-Type")+" "+string(b))
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	h := &eventHooks{postURL: srv.URL, http: srv.Client(), sem: make(chan struct{}, 2)}
+	for i := 0; i < 3; i++ {
+		h.handle(map[string]any{"event_type": "backup_done"})
+	}
+	h.wait()
+
+	if len(bodies) != 3 {
+		t.Fatalf("got %d requests, want 3", len(bodies))
+	}
+	for _, b := range bodies {
+		if b != `application/json {"event_type":"backup_done"}` {
+			t.Errorf("request = %q", b)
+		}
+	}
+}
@@ -5,23 +5,23 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"path"
+	"reflect"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/joaobarroca93/hactl/client"
+	"github.com/joaobarroca93/hactl/expr"
 	"github.com/joaobarroca93/hactl/output"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
-var (
-	eventsType   string
-	eventsDomain string
-)
-
 var eventsCmd = &cobra.Command{
 	Use:   "events",
-	Short: "Stream Home Assistant events",
+	Short: "Stream and fire Home Assistant events",
 }
 
 var eventsWatchCmd = &cobra.Command{
@@ -29,106 +29,558 @@ var eventsWatchCmd = &cobra.Command{
 	Short: "Stream events to stdout as JSON lines",
 	Long: `Connect to the Home Assistant WebSocket API and stream events.
 
+--type may be repeated to watch several event types. --entity (entity IDs,
+friendly names or globs) and --area (area IDs or names) only pass events
+whose data carries an entity_id, such as state_changed. Repeating a flag
+widens it; different flags must all match.
+
+--attr passes state_changed events where one of the given attributes
+changed; --ignore-attributes-only drops state_changed events where only
+attributes changed, such as the power readings of a smart plug.
+
+The watch runs until Ctrl-C unless bounded: --count stops after N events,
+--until stops after the first event matching an expression (fields:
+event_type, entity_id, domain, new_state, old_state, attributes,
+old_attributes, data, origin, time_fired), and --timeout stops after a
+duration. Events before the --until match are printed as usual.
+
+--exec runs a shell command (sh -c) for each printed event, with the event
+as JSON on stdin and HACTL_EVENT_TYPE, HACTL_ENTITY, HACTL_DOMAIN,
+HACTL_OLD_STATE, HACTL_NEW_STATE and HACTL_TIME_FIRED in its environment.
+Its output goes to stderr, keeping stdout a clean event stream. --post
+sends each event as JSON to a URL. Up to --max-concurrent commands or
+requests run at once (1 by default, in event order); failures are reported
+on stderr and do not stop the watch.
+
+--debounce coalesces the events of each entity: the first one opens a
+window, later ones replace it, and the last is emitted when the window
+closes, with the old_state of the first. --aggregate prints, for each
+window, one line per entity with the number of events, the min and max of
+numeric states, and the last state.
+
+--record writes every event that passes the filters, with its arrival time,
+to a file that events replay can play back.
+
+Exit codes:
+  0  --count or --until was reached, or the watch was stopped
+  1  error, or the connection was lost
+  2  --timeout elapsed before --count or --until was reached
+
 Examples:
   hactl events watch
   hactl events watch --type state_changed
+  hactl events watch --type automation_triggered --type script_started
   hactl events watch --domain light
-  hactl events watch --type state_changed --domain motion`,
+  hactl events watch --type state_changed --domain motion
+  hactl events watch --type state_changed --entity 'binary_sensor.*_door'
+  hactl events watch --type state_changed --area kitchen --ignore-attributes-only
+  hactl events watch --type state_changed --entity light.desk --attr brightness
+  hactl events watch --type state_changed --entity 'sensor.*' --count 10
+  hactl events watch --type state_changed --until 'entity_id == "binary_sensor.door" && new_state == "on"' --timeout 5m
+  hactl events watch --type state_changed --entity 'binary_sensor.*' --exec 'notify-send "$HACTL_ENTITY $HACTL_NEW_STATE"'
+  hactl events watch --type automation_triggered --post http://localhost:9000/hook --max-concurrent 4
+  hactl events watch --type state_changed --entity 'binary_sensor.*' --record doors.ndjson
+  hactl events watch --type state_changed --entity 'sensor.*_power' --debounce 2s
+  hactl events watch --type state_changed --domain sensor --aggregate 1m --plain`,
+	Args: cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		token := viper.GetString("hass_token")
-		if token == "" {
-			return output.Err("HASS_TOKEN is required")
+		f, err := newEventFilter(cmd)
+		if err != nil {
+			return output.Err("%s", err)
 		}
-		baseURL := viper.GetString("hass_url")
-		if baseURL == "" {
-			baseURL = "http://homeassistant.local:8123"
+		count, _ := cmd.Flags().GetInt("count")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		var until *expr.Expr
+		if src, _ := cmd.Flags().GetString("until"); src != "" {
+			if until, err = expr.Parse(src); err != nil {
+				return output.Err("invalid --until expression: %s", err)
+			}
+		}
+		debounce, _ := cmd.Flags().GetDuration("debounce")
+		aggregate, _ := cmd.Flags().GetDuration("aggregate")
+		if debounce > 0 && aggregate > 0 {
+			return output.Err("--debounce and --aggregate cannot be combined")
+		}
+		if aggregate > 0 {
+			for _, name := range []string{"count", "until", "exec", "post"} {
+				if cmd.Flags().Changed(name) {
+					return output.Err("--%s cannot be combined with --aggregate: it applies to events, not to window statistics", name)
+				}
+			}
 		}
 
-		ws, err := client.NewWS(baseURL, token)
+		hooks, err := newEventHooks(cmd)
 		if err != nil {
-			return output.Err("websocket: %s", err)
+			return output.Err("%s", err)
 		}
-		defer ws.Close()
-
-		// Subscribe — if --type is given use it, otherwise subscribe to all events
-		if err := ws.SubscribeEvents(eventsType); err != nil {
-			return output.Err("failed to subscribe: %s", err)
+		var recorder *eventRecorder
+		if file, _ := cmd.Flags().GetString("record"); file != "" {
+			if recorder, err = newEventRecorder(file); err != nil {
+				return output.Err("%s", err)
+			}
 		}
+		// Let running hooks finish and close the recording before hactl
+		// exits. output.Err and output.Timeout exit without running
+		// deferred calls, so the exit paths below call finish themselves.
+		finished := false
+		finish := func() {
+			if finished {
+				return
+			}
+			finished = true
+			hooks.wait()
+			if err := recorder.close(); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: --record: %s\n", err)
+			}
+		}
+		defer finish()
 
-		// Read the subscription acknowledgement
-		ack, err := ws.ReadMessage()
+		ws, err := dialWS()
 		if err != nil {
-			return output.Err("websocket: %s", err)
+			finish()
+			return output.Err("%s", err)
+		}
+		sess := ws.StartSession()
+		defer sess.Close()
+
+		// One subscription per --type, or a single one for all events.
+		types := f.types
+		if len(types) == 0 {
+			types = []string{""}
 		}
-		if ack.Success != nil && !*ack.Success {
-			return output.Err("subscription failed: %v", ack.Error)
+		var subs []*client.Subscription
+		for _, t := range types {
+			sub, err := sess.SubscribeEvents(t)
+			if err != nil {
+				finish()
+				return output.Err("failed to subscribe: %s", err)
+			}
+			subs = append(subs, sub)
 		}
+		frames := mergeSubscriptions(subs)
 
 		if !quiet {
 			fmt.Fprintf(os.Stderr, "connected, streaming events (Ctrl-C to stop)...\n")
 		}
 
-		// Handle SIGINT/SIGTERM gracefully
+		// Ctrl-C ends the watch like reaching --count: cleanly, exit 0.
 		sigCh := make(chan os.Signal, 1)
 		signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-		go func() {
-			<-sigCh
-			ws.Close()
-			os.Exit(0)
-		}()
+		defer signal.Stop(sigCh)
 
-		enc := json.NewEncoder(os.Stdout)
+		var deadline <-chan time.Time
+		if timeout > 0 {
+			t := time.NewTimer(timeout)
+			defer t.Stop()
+			deadline = t.C
+		}
 
-		for {
-			raw, err := ws.ReadRaw()
-			if err != nil {
-				// Connection closed
-				break
+		enc := json.NewEncoder(os.Stdout)
+		seen := 0
+		// emit prints an event and runs its hooks. It reports whether
+		// --count or --until has been reached.
+		emit := func(event map[string]any) bool {
+			if !quiet {
+				if plain {
+					fmt.Println(formatEventPlain(event))
+				} else {
+					_ = enc.Encode(event)
+				}
 			}
-
-			var msg map[string]any
-			if err := json.Unmarshal(raw, &msg); err != nil {
-				continue
+			hooks.handle(event)
+			seen++
+			if until != nil && until.Match(eventEnv(event)) {
+				return true
 			}
-
-			msgType, _ := msg["type"].(string)
-			if msgType != "event" {
-				continue
+			return count > 0 && seen >= count
+		}
+		printStats := func(stats []eventStats) {
+			for _, st := range stats {
+				if quiet {
+					continue
+				}
+				if plain {
+					fmt.Println(formatStatsPlain(st))
+				} else {
+					_ = enc.Encode(st)
+				}
 			}
+		}
 
-			event, _ := msg["event"].(map[string]any)
-			if event == nil {
-				continue
+		// --debounce holds events back until their entity's window closes;
+		// --aggregate replaces events by per-window statistics.
+		var debouncer *eventDebouncer
+		var aggregator *eventAggregator
+		var batchC <-chan time.Time
+		batchTimer := time.NewTimer(time.Hour)
+		batchTimer.Stop()
+		defer batchTimer.Stop()
+		switch {
+		case debounce > 0:
+			debouncer = newEventDebouncer(debounce)
+		case aggregate > 0:
+			aggregator = newEventAggregator(time.Now())
+			ticker := time.NewTicker(aggregate)
+			defer ticker.Stop()
+			batchC = ticker.C
+		}
+		rearm := func() {
+			if at, ok := debouncer.next(); ok {
+				batchTimer.Reset(time.Until(at))
+				batchC = batchTimer.C
+			} else {
+				batchC = nil
+			}
+		}
+		// flush emits what is held back when the watch stops.
+		flush := func() {
+			switch {
+			case debouncer != nil:
+				for _, e := range debouncer.flush() {
+					emit(e)
+				}
+			case aggregator != nil:
+				printStats(aggregator.flush(time.Now()))
 			}
+		}
 
-			// Apply domain filter
-			if eventsDomain != "" {
-				if !matchesDomain(event, eventsDomain) {
+		for {
+			select {
+			case raw, ok := <-frames:
+				if !ok {
+					flush()
+					finish()
+					return output.Err("websocket closed: %v", sess.Err())
+				}
+				var msg map[string]any
+				if err := json.Unmarshal(raw, &msg); err != nil {
 					continue
 				}
-			}
+				event, _ := msg["event"].(map[string]any)
+				if event == nil || !f.matches(event) {
+					continue
+				}
+				if err := recorder.record(raw); err != nil {
+					finish()
+					return output.Err("record: %s", err)
+				}
 
-			if quiet {
-				continue
+				switch {
+				case aggregator != nil:
+					aggregator.add(event)
+					continue
+				case debouncer != nil:
+					event = debouncer.add(event, time.Now())
+					rearm()
+					if event == nil {
+						continue
+					}
+				}
+				if emit(event) {
+					return nil
+				}
+			case now := <-batchC:
+				if aggregator != nil {
+					printStats(aggregator.flush(now))
+					continue
+				}
+				for _, e := range debouncer.due(now) {
+					if emit(e) {
+						return nil
+					}
+				}
+				rearm()
+			case <-deadline:
+				flush()
+				finish()
+				if until != nil {
+					return output.Timeout("no event matched --until within %s", timeout)
+				}
+				if count > 0 {
+					return output.Timeout("%d of %d events within %s", seen, count, timeout)
+				}
+				return nil
+			case <-sigCh:
+				flush()
+				return nil
 			}
+		}
+	},
+}
 
-			if plain {
-				fmt.Println(formatEventPlain(event))
-			} else {
-				_ = enc.Encode(event)
-			}
+var eventsFireCmd = &cobra.Command{
+	Use:   "fire <event_type>",
+	Short: "Fire a custom event",
+	Long: `Fire an event on the Home Assistant event bus (POST /api/events/<type>),
+e.g. to trigger automations that listen for a custom event.
+
+The event data is built from --data-file, --data-json and --data, as for
+service call.
+
+In filter.mode: exposed, only event types matching events.fire_allowlist in
+the config file (event types or globs) may be fired:
+
+  events:
+    fire_allowlist:
+      - ci_deploy_finished
+      - hactl_*
+
+Examples:
+  hactl events fire ci_deploy_finished --data env=prod --data version:='"1.10"'
+  hactl events fire hactl_doorbell --data-json '{"camera": "front"}'`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		eventType := args[0]
+		if strings.ContainsAny(eventType, "/ \t") {
+			return output.Err("invalid event type %q", eventType)
+		}
+		if entityFilter.Mode() == "exposed" && !eventFireAllowed(eventType, viper.GetStringSlice("events.fire_allowlist")) {
+			return output.Err(
+				"event type %s is not permitted in exposed mode\n  to enable it, add it to events.fire_allowlist in your config file",
+				eventType,
+			)
+		}
+		data, err := readPayload(cmd)
+		if err != nil {
+			return output.Err("%s", err)
+		}
+		if err := getClient().FireEvent(eventType, data); err != nil {
+			return output.Err("%s", err)
+		}
+		if quiet {
+			return nil
+		}
+		if plain {
+			output.PrintPlain("fired " + eventType)
+			return nil
 		}
-		return nil
+		return output.PrintJSON(map[string]any{"event_type": eventType, "data": data})
 	},
 }
 
 func init() {
-	eventsWatchCmd.Flags().StringVar(&eventsType, "type", "", "filter by event type (e.g. state_changed)")
-	eventsWatchCmd.Flags().StringVar(&eventsDomain, "domain", "", "filter by entity domain (e.g. light, motion)")
+	addPayloadFlags(eventsFireCmd)
+	eventsCmd.AddCommand(eventsFireCmd)
+
+	eventsWatchCmd.Flags().StringArray("type", nil, "filter by event type, e.g. state_changed (repeatable)")
+	eventsWatchCmd.Flags().String("domain", "", "filter by entity domain (e.g. light, motion)")
+	eventsWatchCmd.Flags().StringArray("entity", nil, "filter by entity: entity ID, friendly name or glob (repeatable)")
+	eventsWatchCmd.Flags().StringArray("area", nil, "filter by area ID or name (repeatable)")
+	eventsWatchCmd.Flags().StringArray("attr", nil, "only state changes where this attribute changed (repeatable)")
+	eventsWatchCmd.Flags().Bool("ignore-attributes-only", false, "drop state changes where only attributes changed")
+	eventsWatchCmd.Flags().Int("count", 0, "exit after printing this many events")
+	eventsWatchCmd.Flags().Duration("timeout", 0, "stop after this long; exit 2 if --count or --until was not reached")
+	eventsWatchCmd.Flags().String("until", "", "exit after printing the first event matching this expression")
+	eventsWatchCmd.Flags().String("exec", "", "run this shell command for each event (event JSON on stdin, HACTL_* variables)")
+	eventsWatchCmd.Flags().String("post", "", "POST each event as JSON to this URL")
+	eventsWatchCmd.Flags().Int("max-concurrent", 1, "how many --exec commands or --post requests may run at once")
+	eventsWatchCmd.Flags().Duration("debounce", 0, "coalesce each entity's events over this window and emit the last one")
+	eventsWatchCmd.Flags().Duration("aggregate", 0, "instead of events, emit per-entity counts (and min/max of numeric states) for each window of this length")
+	eventsWatchCmd.Flags().String("record", "", "also write each event frame with its arrival time to this file (NDJSON, for events replay)")
 
 	eventsCmd.AddCommand(eventsWatchCmd)
 }
 
+// eventFireAllowed reports whether eventType matches one of the allowlist
+// entries, which are event types or globs.
+func eventFireAllowed(eventType string, allowlist []string) bool {
+	for _, pattern := range allowlist {
+		if ok, _ := path.Match(pattern, eventType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// eventFilter decides which events events watch prints. Values within one
+// field are alternatives; every non-empty field must match.
+type eventFilter struct {
+	types    []string
+	domain   string
+	entities []string        // entity IDs and globs
+	areas    map[string]bool // entities in the --area areas; nil without --area
+	attrs    []string
+
+	ignoreAttributesOnly bool
+}
+
+// newEventFilter builds the filter from the events watch flags. Entity
+// references that are not globs are resolved like everywhere else, so a
+// typo or a hidden entity fails immediately instead of matching nothing.
+func newEventFilter(cmd *cobra.Command) (*eventFilter, error) {
+	f := &eventFilter{}
+	f.types, _ = cmd.Flags().GetStringArray("type")
+	f.domain, _ = cmd.Flags().GetString("domain")
+	f.attrs, _ = cmd.Flags().GetStringArray("attr")
+	f.ignoreAttributesOnly, _ = cmd.Flags().GetBool("ignore-attributes-only")
+
+	refs, _ := cmd.Flags().GetStringArray("entity")
+	for _, ref := range refs {
+		if isGlob(ref) {
+			if _, err := path.Match(ref, ""); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %s", ref, err)
+			}
+			f.entities = append(f.entities, ref)
+			continue
+		}
+		id, err := resolveEntity(ref, "")
+		if err != nil {
+			return nil, err
+		}
+		f.entities = append(f.entities, id)
+	}
+
+	if areaRefs, _ := cmd.Flags().GetStringArray("area"); len(areaRefs) > 0 {
+		ws, err := dialWS()
+		if err != nil {
+			return nil, err
+		}
+		idx, err := ws.FetchTargetIndex()
+		ws.Close()
+		if err != nil {
+			return nil, err
+		}
+		if f.areas, err = areaEntities(idx, areaRefs); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// areaEntities returns the entities in the areas refs refers to by ID or
+// name, the way service call --area expands them.
+func areaEntities(idx *client.TargetIndex, refs []string) (map[string]bool, error) {
+	targets, err := resolveRegistryTargets(idx, refs, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	members := map[string]bool{}
+	for _, id := range targetMembers(idx, targets, "") {
+		members[id] = true
+	}
+	return members, nil
+}
+
+// matches reports whether event passes the filter.
+func (f *eventFilter) matches(event map[string]any) bool {
+	eventType, _ := event["event_type"].(string)
+	if len(f.types) > 0 && !containsString(f.types, eventType) {
+		return false
+	}
+	if f.domain != "" && !matchesDomain(event, f.domain) {
+		return false
+	}
+
+	data, _ := event["data"].(map[string]any)
+	entityID, _ := data["entity_id"].(string)
+	if len(f.entities) > 0 && !f.matchesEntity(entityID) {
+		return false
+	}
+	if f.areas != nil && !f.areas[entityID] {
+		return false
+	}
+
+	if len(f.attrs) == 0 && !f.ignoreAttributesOnly {
+		return true
+	}
+	if eventType != "state_changed" {
+		return false
+	}
+	oldState, _ := data["old_state"].(map[string]any)
+	newState, _ := data["new_state"].(map[string]any)
+	if f.ignoreAttributesOnly && oldState != nil && newState != nil && oldState["state"] == newState["state"] {
+		return false
+	}
+	if len(f.attrs) > 0 {
+		oldAttrs, _ := oldState["attributes"].(map[string]any)
+		newAttrs, _ := newState["attributes"].(map[string]any)
+		for _, a := range f.attrs {
+			if !reflect.DeepEqual(oldAttrs[a], newAttrs[a]) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// matchesEntity reports whether entityID is one of the filter's entities or
+// matches one of its globs. Globs only ever match entities visible through
+// the entity filter.
+func (f *eventFilter) matchesEntity(entityID string) bool {
+	if entityID == "" {
+		return false
+	}
+	for _, e := range f.entities {
+		if e == entityID {
+			return true
+		}
+		if ok, _ := path.Match(e, entityID); ok && isGlob(e) && entityFilter.IsAllowed(entityID) {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeSubscriptions forwards the frames of several subscriptions to one
+// channel, which is closed once all of them are.
+func mergeSubscriptions(subs []*client.Subscription) <-chan []byte {
+	if len(subs) == 1 {
+		return subs[0].C
+	}
+	out := make(chan []byte)
+	var wg sync.WaitGroup
+	for _, sub := range subs {
+		wg.Add(1)
+		go func(c <-chan []byte) {
+			defer wg.Done()
+			for raw := range c {
+				out <- raw
+			}
+		}(sub.C)
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// eventEnv returns the fields --until expressions can refer to. For events
+// about an entity, new_state and old_state are the state strings and
+// attributes are the new attributes; data holds the raw event data.
+func eventEnv(event map[string]any) map[string]any {
+	data, _ := event["data"].(map[string]any)
+	if data == nil {
+		data = map[string]any{}
+	}
+	env := map[string]any{
+		"event_type": event["event_type"],
+		"origin":     event["origin"],
+		"context":    event["context"],
+		"data":       data,
+	}
+	if fired, ok := event["time_fired"].(string); ok {
+		if t, err := time.Parse(time.RFC3339Nano, fired); err == nil {
+			env["time_fired"] = t
+		}
+	}
+	if entityID, ok := data["entity_id"].(string); ok {
+		domain, _, _ := strings.Cut(entityID, ".")
+		env["entity_id"] = entityID
+		env["domain"] = domain
+	}
+	if newState, ok := data["new_state"].(map[string]any); ok {
+		env["new_state"] = newState["state"]
+		env["attributes"] = newState["attributes"]
+	}
+	if oldState, ok := data["old_state"].(map[string]any); ok {
+		env["old_state"] = oldState["state"]
+		env["old_attributes"] = oldState["attributes"]
+	}
+	return env
+}
+
 // matchesDomain checks if a state_changed event's entity_id matches the given domain.
 func matchesDomain(event map[string]any, domain string) bool {
 	data, _ := event["data"].(map[string]any)
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/joaobarroca93/hactl/expr"
+	"github.com/joaobarroca93/hactl/filter"
+)
+
+func stateChangedEvent(entityID, oldState, newState string, oldAttrs, newAttrs map[string]any) map[string]any {
+	return map[string]any{
+		"event_type": "state_changed",
+		"data": map[string]any{
+			"entity_id": entityID,
+			"old_state": map[string]any{"state": oldState, "attributes": oldAttrs},
+			"new_state": map[string]any{"state": newState, "attributes": newAttrs},
+		},
+	}
+}
+
+func TestEventFilterMatches(t *testing.T) {
+	useFilter(t, filter.New("all", true))
+
+	doorOpened := stateChangedEvent("binary_sensor.front_door", "off", "on", nil, nil)
+	powerReading := stateChangedEvent("sensor.plug_power", "12.1", "12.1",
+		map[string]any{"current": 0.05}, map[string]any{"current": 0.06})
+	dimmed := stateChangedEvent("light.desk", "on", "on",
+		map[string]any{"brightness": 255.0, "color_mode": "brightness"},
+		map[string]any{"brightness": 128.0, "color_mode": "brightness"})
+	automation := map[string]any{
+		"event_type": "automation_triggered",
+		"data":       map[string]any{"entity_id": "automation.night", "name": "Night"},
+	}
+	custom := map[string]any{"event_type": "backup_done", "data": map[string]any{}}
+
+	tests := []struct {
+		name   string
+		filter eventFilter
+		event  map[string]any
+		want   bool
+	}{
+		{"no filter", eventFilter{}, custom, true},
+		{"type match", eventFilter{types: []string{"automation_triggered", "state_changed"}}, automation, true},
+		{"type mismatch", eventFilter{types: []string{"state_changed"}}, custom, false},
+		{"domain", eventFilter{domain: "binary_sensor"}, doorOpened, true},
+		{"entity exact", eventFilter{entities: []string{"light.desk"}}, dimmed, true},
+		{"entity glob", eventFilter{entities: []string{"binary_sensor.*_door"}}, doorOpened, true},
+		{"entity glob mismatch", eventFilter{entities: []string{"light.*"}}, doorOpened, false},
+		{"entity needs entity_id", eventFilter{entities: []string{"*"}}, custom, false},
+		{"entity on other event types", eventFilter{entities: []string{"automation.*"}}, automation, true},
+		{"ignore attributes only drops", eventFilter{ignoreAttributesOnly: true}, powerReading, false},
+		{"ignore attributes only keeps", eventFilter{ignoreAttributesOnly: true}, doorOpened, true},
+		{"ignore attributes only needs state_changed", eventFilter{ignoreAttributesOnly: true}, custom, false},
+		{"attr changed", eventFilter{attrs: []string{"brightness"}}, dimmed, true},
+		{"attr unchanged", eventFilter{attrs: []string{"color_mode"}}, dimmed, false},
+		{"attr any of", eventFilter{attrs: []string{"color_mode", "brightness"}}, dimmed, true},
+		{"attr and ignore attributes only", eventFilter{attrs: []string{"brightness"}, ignoreAttributesOnly: true}, dimmed, false},
+		{"area", eventFilter{areas: map[string]bool{"light.desk": true}}, dimmed, true},
+		{"area mismatch", eventFilter{areas: map[string]bool{"light.desk": true}}, doorOpened, false},
+		{"area needs entity_id", eventFilter{areas: map[string]bool{}}, custom, false},
+		{"flags combine", eventFilter{types: []string{"state_changed"}, entities: []string{"light.*"}, attrs: []string{"brightness"}}, dimmed, true},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.matches(tt.event); got != tt.want {
+			t.Errorf("%s: matches() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAreaEntities(t *testing.T) {
+	idx := targetIndexFixture()
+	got, err := areaEntities(idx, []string{"Living Room", "kitchen"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 4 || !got["light.sofa"] || !got["switch.kitchen_relay"] {
+		t.Errorf("areaEntities() = %v", got)
+	}
+	if _, err := areaEntities(idx, []string{"garage"}); err == nil || err.Error() != "area not found: garage" {
+		t.Errorf("unknown area: got %v", err)
+	}
+}
+
+func TestEventFilterGlobsSkipHiddenEntities(t *testing.T) {
+	useFilter(t, filter.New("exposed", true)) // no cache: nothing is exposed
+	f := eventFilter{entities: []string{"light.*"}}
+	if f.matches(stateChangedEvent("light.desk", "off", "on", nil, nil)) {
+		t.Error("a glob must not match entities hidden by the filter")
+	}
+}
+
+func TestEventEnvUntil(t *testing.T) {
+	opened := stateChangedEvent("binary_sensor.door", "off", "on", nil, map[string]any{"device_class": "door"})
+	opened["time_fired"] = "2024-01-05T07:30:00.123456+00:00"
+	custom := map[string]any{"event_type": "backup_done", "data": map[string]any{"size": 42.0}}
+
+	tests := []struct {
+		src   string
+		event map[string]any
+		want  bool
+	}{
+		{`entity_id == "binary_sensor.door" && new_state == "on"`, opened, true},
+		{`entity_id == "binary_sensor.door" && new_state == "off"`, opened, false},
+		{`domain == "binary_sensor" && old_state == "off"`, opened, true},
+		{`attributes.device_class == "door"`, opened, true},
+		{`time_fired < -1m`, opened, true},
+		{`event_type == "backup_done" && data.size > 40`, custom, true},
+		{`new_state == "on"`, custom, false},
+	}
+	for _, tt := range tests {
+		e, err := expr.Parse(tt.src)
+		if err != nil {
+			t.Fatalf("parse %q: %v", tt.src, err)
+		}
+		if got := e.Match(eventEnv(tt.event)); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestEventFireAllowed(t *testing.T) {
+	allowlist := []string{"ci_deploy_finished", "hactl_*"}
+	tests := []struct {
+		eventType string
+		want      bool
+	}{
+		{"ci_deploy_finished", true},
+		{"hactl_doorbell", true},
+		{"ci_deploy_started", false},
+		{"call_service", false},
+		{"homeassistant_stop", false},
+	}
+	for _, tt := range tests {
+		if got := eventFireAllowed(tt.eventType, allowlist); got != tt.want {
+			t.Errorf("eventFireAllowed(%q) = %v, want %v", tt.eventType, got, tt.want)
+		}
+	}
+	if eventFireAllowed("anything", nil) {
+		t.Error("an empty allowlist must allow nothing")
+	}
+}
@@ -0,0 +1,220 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/joaobarroca93/hactl/client"
+	"github.com/spf13/viper"
+)
+
+// fakeHA is a minimal Home Assistant for tests: it serves /api/states/<id>
+// and /api/services/<domain>/<service> over REST, the service descriptions
+// at /api/services, an empty history at /api/history/period/, and the
+// WebSocket API
+// with subscribe_events and call_service. getClient and dialWS point at it
+// for the duration of the test.
+type fakeHA struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	states  map[string]client.State
+	changed []client.State // response to REST service calls
+	calls   []string       // REST service calls, as domain.service
+	// services is the JSON body of GET /api/services; empty means none.
+	services string
+	// historyQueries holds the query of every history request.
+	historyQueries []url.Values
+	conns   []*fakeHAConn
+
+	// onCall handles a WebSocket call_service command; nil acknowledges it
+	// without a context.
+	onCall func(c *fakeHAConn, id int, msg map[string]any)
+	// subscribed receives a value for every subscribe_events command.
+	subscribed chan struct{}
+}
+
+// fakeHAConn is one WebSocket client of a fakeHA.
+type fakeHAConn struct {
+	conn *websocket.Conn
+
+	mu   sync.Mutex
+	subs map[int]string // subscription id -> event type ("" for all)
+}
+
+func newFakeHA(t *testing.T) *fakeHA {
+	t.Helper()
+	f := &fakeHA{states: map[string]client.State{}, subscribed: make(chan struct{}, 16)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/websocket", f.handleWS)
+	mux.HandleFunc("/api/states/", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		s, ok := f.states[strings.TrimPrefix(r.URL.Path, "/api/states/")]
+		f.mu.Unlock()
+		if !ok {
+			http.Error(w, `{"message": "Entity not found."}`, http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(s)
+	})
+	mux.HandleFunc("/api/history/period/", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.historyQueries = append(f.historyQueries, r.URL.Query())
+		f.mu.Unlock()
+		_, _ = w.Write([]byte("[]"))
+	})
+	mux.HandleFunc("/api/services", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		body := f.services
+		f.mu.Unlock()
+		if body == "" {
+			body = "[]"
+		}
+		_, _ = w.Write([]byte(body))
+	})
+	mux.HandleFunc("/api/services/", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.calls = append(f.calls, strings.ReplaceAll(strings.TrimPrefix(r.URL.Path, "/api/services/"), "/", "."))
+		changed := f.changed
+		f.mu.Unlock()
+		if changed == nil {
+			changed = []client.State{}
+		}
+		_ = json.NewEncoder(w).Encode(changed)
+	})
+	f.Server = httptest.NewServer(mux)
+
+	oldClient, oldURL, oldToken := restClient, viper.Get("hass_url"), viper.Get("hass_token")
+	restClient = client.New(f.URL, "test-token")
+	viper.Set("hass_url", f.URL)
+	viper.Set("hass_token", "test-token")
+	t.Cleanup(func() {
+		f.dropConnections()
+		f.Close()
+		restClient = oldClient
+		viper.Set("hass_url", oldURL)
+		viper.Set("hass_token", oldToken)
+	})
+	return f
+}
+
+func (f *fakeHA) setState(s client.State) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.states[s.EntityID] = s
+}
+
+func (f *fakeHA) handleWS(w http.ResponseWriter, r *http.Request) {
+	conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c := &fakeHAConn{conn: conn, subs: map[int]string{}}
+	defer conn.Close()
+	if c.send(map[string]any{"type": "auth_required"}) != nil {
+		return
+	}
+	var auth map[string]any
+	if conn.ReadJSON(&auth) != nil || c.send(map[string]any{"type": "auth_ok"}) != nil {
+		return
+	}
+	f.mu.Lock()
+	f.conns = append(f.conns, c)
+	f.mu.Unlock()
+
+	for {
+		var msg map[string]any
+		if conn.ReadJSON(&msg) != nil {
+			return
+		}
+		id := int(msg["id"].(float64))
+		switch msg["type"] {
+		case "subscribe_events":
+			eventType, _ := msg["event_type"].(string)
+			c.mu.Lock()
+			c.subs[id] = eventType
+			c.mu.Unlock()
+			c.result(id, nil)
+			f.subscribed <- struct{}{}
+		case "call_service":
+			if f.onCall != nil {
+				f.onCall(c, id, msg)
+			} else {
+				c.result(id, nil)
+			}
+		default:
+			c.result(id, nil)
+		}
+	}
+}
+
+// dropConnections closes every WebSocket connection, as when Home Assistant
+// restarts.
+func (f *fakeHA) dropConnections() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, c := range f.conns {
+		c.conn.Close()
+	}
+	f.conns = nil
+}
+
+// fire sends event to every client subscribed to its type.
+func (f *fakeHA) fire(event map[string]any) {
+	f.mu.Lock()
+	conns := append([]*fakeHAConn(nil), f.conns...)
+	f.mu.Unlock()
+	for _, c := range conns {
+		c.fire(event)
+	}
+}
+
+func (c *fakeHAConn) send(v any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn.WriteJSON(v)
+}
+
+// result acknowledges command id with result.
+func (c *fakeHAConn) result(id int, result any) {
+	_ = c.send(map[string]any{"id": id, "type": "result", "success": true, "result": result})
+}
+
+// fire sends event to this client's matching subscriptions.
+func (c *fakeHAConn) fire(event map[string]any) {
+	eventType, _ := event["event_type"].(string)
+	c.mu.Lock()
+	var ids []int
+	for id, t := range c.subs {
+		if t == "" || t == eventType {
+			ids = append(ids, id)
+		}
+	}
+	c.mu.Unlock()
+	for _, id := range ids {
+		_ = c.send(map[string]any{"id": id, "type": "event", "event": event})
+	}
+}
+
+// contextStateEvent returns a state_changed event whose new state carries
+// the context id contextID with parent parentID.
+func contextStateEvent(entityID, state, contextID, parentID string) map[string]any {
+	ctx := map[string]any{"id": contextID, "parent_id": nil}
+	if parentID != "" {
+		ctx["parent_id"] = parentID
+	}
+	return map[string]any{
+		"event_type": "state_changed",
+		"context":    ctx,
+		"data": map[string]any{
+			"entity_id": entityID,
+			"new_state": map[string]any{"entity_id": entityID, "state": state, "attributes": map[string]any{}, "context": ctx},
+		},
+	}
+}
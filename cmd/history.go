@@ -2,6 +2,9 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"regexp"
+	"sort"
 	"strings"
 	"time"
 
@@ -13,57 +16,351 @@ import (
 var historyLast string
 
 var historyCmd = &cobra.Command{
-	Use:   "history <entity_id>",
-	Short: "Show state history for an entity",
-	Long: `Show state history for an entity over a time window.
+	Use:   "history <entity>...",
+	Short: "Show state history for entities",
+	Long: `Show state history for one or more entities over a time window.
+
+Entities are entity IDs, friendly names or globs. The window is the last
+--last (1h by default), or given with --start and --end, which accept
+RFC3339 times and natural forms: "yesterday 18:00", "today 07:30", "18:00"
+(today), "2026-10-17", "2026-10-17 18:00", "3h ago", "2d ago" and "now".
+With only one of --start or --end, the other is --last away from it (or now
+for --start).
+
+For a single entity, JSON output is the list of its states and plain output
+a summary of its transitions. For several entities (or a glob), JSON output
+is keyed by entity ID and plain output merges every transition into one
+chronological list, which is also the plain output for any entity with
+--start or --end.
+
+--format csv writes every recorded state as a row of timestamp, entity_id
+and state, plus a column per --attr attribute, for loading into notebooks
+and spreadsheets. With --attr, updates that only changed attributes get
+their own rows too.
+
+--resample turns the states of numeric sensors into a regular series with
+one value per interval, aligned to multiples of it: --agg mean (the default)
+is the time-weighted mean over the interval, max the highest value and last
+the value at its end. Intervals without a number (unavailable) are empty.
 
 Examples:
   hactl history sensor.temperature --last 1h
-  hactl history light.living_room --last 24h --plain`,
-	Args: cobra.ExactArgs(1),
+  hactl history light.living_room --last 24h --plain
+  hactl history 'binary_sensor.*_door' --start "yesterday 18:00" --end "today 07:00" --plain
+  hactl history sensor.indoor_temperature sensor.outdoor_temperature --start 2026-10-17
+  hactl history climate.living_room --last 7d --format csv --attr current_temperature --attr hvac_action
+  hactl history 'sensor.*_power' --last 24h --resample 5m --agg mean --format csv > power.csv`,
+	Args: cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		entityID := args[0]
-		if !entityFilter.IsAllowed(entityID) {
-			return output.Err("entity not found: %s", entityID)
+		entityIDs, err := resolveServiceEntities(args, "")
+		if err != nil {
+			return output.Err("%s", err)
 		}
+		single := len(args) == 1 && !isGlob(args[0])
 
-		duration, err := time.ParseDuration(historyLast)
+		format, _ := cmd.Flags().GetString("format")
+		attrs, _ := cmd.Flags().GetStringSlice("attr")
+		resampleFlag, _ := cmd.Flags().GetString("resample")
+		agg, _ := cmd.Flags().GetString("agg")
+		every, err := checkHistoryExport(format, attrs, resampleFlag, agg, cmd.Flags().Changed("agg"))
 		if err != nil {
-			return output.Err("invalid --last value %q: use values like 1h, 30m, 24h", historyLast)
+			return output.Err("%s", err)
 		}
 
-		start := time.Now().Add(-duration)
-		history, err := getClient().GetHistory(entityID, start, duration)
+		startFlag, _ := cmd.Flags().GetString("start")
+		endFlag, _ := cmd.Flags().GetString("end")
+		start, end, err := historyWindow(startFlag, endFlag, historyLast, cmd.Flags().Changed("last"), time.Now())
+		if err != nil {
+			return output.Err("%s", err)
+		}
+		absolute := startFlag != "" || endFlag != ""
+		window := "in the last " + historyLast
+		if absolute {
+			window = fmt.Sprintf("between %s and %s", start.Local().Format("2006-01-02 15:04"), end.Local().Format("2006-01-02 15:04"))
+		}
+
+		// --attr columns need every attribute update, not only the
+		// significant changes Home Assistant returns by default.
+		byEntity, err := fetchHistory(entityIDs, start, end, len(attrs) > 0)
 		if err != nil {
 			return output.Err("%s", err)
 		}
 
-		if len(history) == 0 || len(history[0]) == 0 {
-			if !quiet {
+		if quiet {
+			return nil
+		}
+		if every > 0 {
+			return printResampled(entityIDs, byEntity, start, end, every, agg, format, single)
+		}
+		if format == "csv" {
+			if err := writeHistoryCSV(os.Stdout, entityIDs, byEntity, attrs); err != nil {
+				return output.Err("%s", err)
+			}
+			return nil
+		}
+		if single {
+			entityID := entityIDs[0]
+			entries := byEntity[entityID]
+			if len(entries) == 0 {
 				if plain {
-					output.PrintPlain(fmt.Sprintf("no history for %s in the last %s", entityID, historyLast))
+					output.PrintPlain(fmt.Sprintf("no history for %s %s", entityID, window))
 				} else {
 					_ = output.PrintJSON([]any{})
 				}
+				return nil
+			}
+			if plain && !absolute {
+				output.PrintPlain(buildHistoryPlain(entries, historyLast))
+				return nil
+			}
+			if !plain {
+				return output.PrintJSON(entries)
 			}
-			return nil
-		}
-
-		entries := history[0]
-
-		if quiet {
-			return nil
 		}
 		if plain {
-			output.PrintPlain(buildHistoryPlain(entries, historyLast))
+			// Several entities, or an absolute window, which can span days
+			// and end in the past: list every transition with its date.
+			lines := mergeHistoryPlain(byEntity)
+			if len(lines) == 0 {
+				output.PrintPlain(fmt.Sprintf("no history for %d entities %s", len(entityIDs), window))
+			}
+			for _, line := range lines {
+				output.PrintPlain(line)
+			}
 			return nil
 		}
-		return output.PrintJSON(entries)
+		return output.PrintJSON(byEntity)
 	},
 }
 
 func init() {
 	historyCmd.Flags().StringVar(&historyLast, "last", "1h", "time window (e.g. 1h, 2h, 24h)")
+	historyCmd.Flags().String("start", "", `start of the window (RFC3339, "yesterday 18:00", "3h ago", …)`)
+	historyCmd.Flags().String("end", "", "end of the window (same forms as --start)")
+	historyCmd.Flags().String("format", "json", "output format: json or csv")
+	historyCmd.Flags().StringSlice("attr", nil, "attribute to add as a CSV column (repeatable)")
+	historyCmd.Flags().String("resample", "", "resample numeric states to a regular interval (e.g. 5m, 1h)")
+	historyCmd.Flags().String("agg", "mean", "how --resample combines states: mean, last or max")
+}
+
+// fetchHistory returns the history of each entity between start and end,
+// keyed by entity ID, with an empty list for entities without history.
+// attributeChanges includes attribute-only updates.
+func fetchHistory(entityIDs []string, start, end time.Time, attributeChanges bool) (map[string][]client.HistoryEntry, error) {
+	history, err := getClient().GetHistory(entityIDs, start, end, attributeChanges)
+	if err != nil {
+		return nil, err
+	}
+	byEntity := map[string][]client.HistoryEntry{}
+	for _, id := range entityIDs {
+		byEntity[id] = []client.HistoryEntry{}
+	}
+	for _, entries := range history {
+		if len(entries) > 0 {
+			if _, ok := byEntity[entries[0].EntityID]; ok {
+				byEntity[entries[0].EntityID] = entries
+			}
+		}
+	}
+	return byEntity, nil
+}
+
+// checkHistoryExport validates the export flags of history and returns the
+// --resample interval, 0 when not resampling.
+func checkHistoryExport(format string, attrs []string, resample, agg string, aggSet bool) (time.Duration, error) {
+	if format != "json" && format != "csv" {
+		return 0, fmt.Errorf("--format must be json or csv, got: %s", format)
+	}
+	if format == "csv" && plain {
+		return 0, fmt.Errorf("--plain and --format csv cannot be combined")
+	}
+	if len(attrs) > 0 && format != "csv" {
+		return 0, fmt.Errorf("--attr needs --format csv: JSON output already has every attribute")
+	}
+	if resample == "" {
+		if aggSet {
+			return 0, fmt.Errorf("--agg needs --resample")
+		}
+		return 0, nil
+	}
+	every, err := parseHistoryDuration(resample)
+	if err != nil || every <= 0 {
+		return 0, fmt.Errorf("invalid --resample value %q: use values like 5m, 1h", resample)
+	}
+	if !containsString([]string{"mean", "last", "max"}, agg) {
+		return 0, fmt.Errorf("--agg must be one of: mean, last, max")
+	}
+	if len(attrs) > 0 {
+		return 0, fmt.Errorf("--attr cannot be combined with --resample")
+	}
+	return every, nil
+}
+
+// printResampled resamples the history of every entity and prints it in
+// the requested format.
+func printResampled(entityIDs []string, byEntity map[string][]client.HistoryEntry, start, end time.Time, every time.Duration, agg, format string, single bool) error {
+	var all []resampledPoint
+	series := map[string][]resampledPoint{}
+	for _, id := range entityIDs {
+		points, err := resampleHistory(id, byEntity[id], start, end, every, agg)
+		if err != nil {
+			return output.Err("%s", err)
+		}
+		series[id] = points
+		all = append(all, points...)
+	}
+
+	switch {
+	case format == "csv":
+		if err := writeResampledCSV(os.Stdout, all); err != nil {
+			return output.Err("%s", err)
+		}
+		return nil
+	case plain:
+		sort.SliceStable(all, func(i, j int) bool { return all[i].Timestamp.Before(all[j].Timestamp) })
+		for _, p := range all {
+			value := "no data"
+			if p.Value != nil {
+				value = formatStat(*p.Value)
+			}
+			output.PrintPlain(fmt.Sprintf("%s %s: %s", p.Timestamp.Local().Format("2006-01-02 15:04:05"), p.EntityID, value))
+		}
+		return nil
+	case single:
+		return output.PrintJSON(series[entityIDs[0]])
+	}
+	return output.PrintJSON(series)
+}
+
+// historyWindow returns the time range for the history flags. last applies
+// when start or end is missing; giving all three is an error.
+func historyWindow(startFlag, endFlag, last string, lastSet bool, now time.Time) (time.Time, time.Time, error) {
+	duration, err := parseHistoryDuration(last)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid --last value %q: use values like 1h, 30m, 24h", last)
+	}
+	if startFlag != "" && endFlag != "" && lastSet {
+		return time.Time{}, time.Time{}, fmt.Errorf("give at most two of --start, --end and --last")
+	}
+
+	var start, end time.Time
+	if startFlag != "" {
+		if start, err = parseTimeArg(startFlag, now); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid --start: %s", err)
+		}
+	}
+	if endFlag != "" {
+		if end, err = parseTimeArg(endFlag, now); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid --end: %s", err)
+		}
+	}
+	switch {
+	case startFlag == "" && endFlag == "":
+		start, end = now.Add(-duration), now
+	case endFlag == "":
+		end = now
+		if lastSet {
+			end = start.Add(duration)
+		}
+	case startFlag == "":
+		start = end.Add(-duration)
+	}
+	if !end.After(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("the window ends before it starts (%s to %s)", start.Local().Format(time.RFC3339), end.Local().Format(time.RFC3339))
+	}
+	return start, end, nil
+}
+
+// parseHistoryDuration parses a Go duration, also accepting whole days
+// such as 7d.
+func parseHistoryDuration(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		var n int
+		if _, err := fmt.Sscanf(days, "%d", &n); err == nil && fmt.Sprint(n) == days {
+			return time.Duration(n) * 24 * time.Hour, nil
+		}
+	}
+	return time.ParseDuration(s)
+}
+
+var timeAgoRe = regexp.MustCompile(`^(\S+)\s+ago$`)
+
+// parseTimeArg parses an absolute or natural time relative to now, in the
+// local time zone unless the value carries its own.
+func parseTimeArg(raw string, now time.Time) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	s := strings.ToLower(raw)
+	if s == "now" {
+		return now, nil
+	}
+	if m := timeAgoRe.FindStringSubmatch(s); m != nil {
+		d, err := parseHistoryDuration(m[1])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid duration %q", m[1])
+		}
+		return now.Add(-d), nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, raw, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+
+	// [today|yesterday] [HH:MM[:SS]]
+	day := now
+	rest := s
+	for _, word := range []string{"today", "yesterday"} {
+		if after, ok := strings.CutPrefix(s, word); ok {
+			if word == "yesterday" {
+				day = now.AddDate(0, 0, -1)
+			}
+			rest = strings.TrimSpace(after)
+		}
+	}
+	midnight := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, now.Location())
+	if rest == "" && rest != s {
+		return midnight, nil
+	}
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		if t, err := time.Parse(layout, rest); err == nil {
+			return midnight.Add(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized time %q: use RFC3339, 2026-10-17 18:00, yesterday 18:00, 18:00, 3h ago or now", raw)
+}
+
+// mergeHistoryPlain returns one line per state transition of every entity,
+// in chronological order.
+func mergeHistoryPlain(byEntity map[string][]client.HistoryEntry) []string {
+	type line struct {
+		t    time.Time
+		text string
+	}
+	var lines []line
+	for id, entries := range byEntity {
+		last := ""
+		for i, e := range entries {
+			if i > 0 && e.State == last {
+				continue
+			}
+			last = e.State
+			lines = append(lines, line{e.LastChanged, fmt.Sprintf("%s %s: %s", e.LastChanged.Local().Format("2006-01-02 15:04:05"), id, e.State)})
+		}
+	}
+	sort.SliceStable(lines, func(i, j int) bool {
+		if lines[i].t.Equal(lines[j].t) {
+			return lines[i].text < lines[j].text
+		}
+		return lines[i].t.Before(lines[j].t)
+	})
+	out := make([]string, len(lines))
+	for i, l := range lines {
+		out[i] = l.text
+	}
+	return out
 }
 
 // buildHistoryPlain returns compact prose describing state transitions.
@@ -112,4 +409,3 @@ func buildHistoryPlain(entries []client.HistoryEntry, window string) string {
 
 	return strings.Join(parts, ", ")
 }
-
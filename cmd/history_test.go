@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/joaobarroca93/hactl/client"
+)
+
+func TestParseTimeArg(t *testing.T) {
+	loc := time.FixedZone("test", 3600)
+	now := time.Date(2026, 10, 18, 9, 30, 0, 0, loc)
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"now", now},
+		{"3h ago", now.Add(-3 * time.Hour)},
+		{"2d ago", now.Add(-48 * time.Hour)},
+		{"2026-10-17T18:00:00Z", time.Date(2026, 10, 17, 18, 0, 0, 0, time.UTC)},
+		{"2026-10-17", time.Date(2026, 10, 17, 0, 0, 0, 0, loc)},
+		{"2026-10-17 18:00", time.Date(2026, 10, 17, 18, 0, 0, 0, loc)},
+		{"2026-10-17T18:00:30", time.Date(2026, 10, 17, 18, 0, 30, 0, loc)},
+		{"18:00", time.Date(2026, 10, 18, 18, 0, 0, 0, loc)},
+		{"today", time.Date(2026, 10, 18, 0, 0, 0, 0, loc)},
+		{"today 07:30", time.Date(2026, 10, 18, 7, 30, 0, 0, loc)},
+		{"Yesterday 18:00", time.Date(2026, 10, 17, 18, 0, 0, 0, loc)},
+		{"yesterday", time.Date(2026, 10, 17, 0, 0, 0, 0, loc)},
+	}
+	for _, tt := range tests {
+		got, err := parseTimeArg(tt.in, now)
+		if err != nil {
+			t.Errorf("parseTimeArg(%q): %v", tt.in, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseTimeArg(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "tomorrow", "yesterday 25:00", "soon ago", "17/10/2026"} {
+		if _, err := parseTimeArg(in, now); err == nil {
+			t.Errorf("parseTimeArg(%q) should fail", in)
+		}
+	}
+}
+
+func TestHistoryWindow(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		start, end string
+		last       string
+		lastSet    bool
+		wantStart  time.Time
+		wantEnd    time.Time
+		wantErr    bool
+	}{
+		{name: "default", last: "1h", wantStart: now.Add(-time.Hour), wantEnd: now},
+		{name: "last in days", last: "7d", lastSet: true, wantStart: now.Add(-7 * 24 * time.Hour), wantEnd: now},
+		{name: "start only", start: "3h ago", last: "1h", wantStart: now.Add(-3 * time.Hour), wantEnd: now},
+		{name: "start and last", start: "3h ago", last: "1h", lastSet: true, wantStart: now.Add(-3 * time.Hour), wantEnd: now.Add(-2 * time.Hour)},
+		{name: "end and last", end: "2h ago", last: "30m", wantStart: now.Add(-150 * time.Minute), wantEnd: now.Add(-2 * time.Hour)},
+		{name: "start and end", start: "5h ago", end: "1h ago", last: "1h", wantStart: now.Add(-5 * time.Hour), wantEnd: now.Add(-time.Hour)},
+		{name: "all three", start: "5h ago", end: "1h ago", last: "1h", lastSet: true, wantErr: true},
+		{name: "end before start", start: "1h ago", end: "5h ago", last: "1h", wantErr: true},
+		{name: "bad start", start: "whenever", last: "1h", wantErr: true},
+		{name: "bad last", last: "soon", lastSet: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, err := historyWindow(tt.start, tt.end, tt.last, tt.lastSet, now)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v to %v", start, end)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) {
+				t.Errorf("window = %v to %v, want %v to %v", start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestMergeHistoryPlain(t *testing.T) {
+	t0 := time.Date(2026, 10, 17, 18, 0, 0, 0, time.UTC)
+	at := func(m int) time.Time { return t0.Add(time.Duration(m) * time.Minute) }
+	byEntity := map[string][]client.HistoryEntry{
+		"binary_sensor.front_door": {
+			{State: "off", LastChanged: at(0)},
+			{State: "on", LastChanged: at(30)},
+			{State: "on", LastChanged: at(31)},
+			{State: "off", LastChanged: at(32)},
+		},
+		"binary_sensor.back_door": {
+			{State: "off", LastChanged: at(0)},
+			{State: "on", LastChanged: at(10)},
+		},
+		"binary_sensor.garage_door": {},
+	}
+	line := func(m int, id, state string) string {
+		return at(m).Local().Format("2006-01-02 15:04:05") + " " + id + ": " + state
+	}
+	want := []string{
+		line(0, "binary_sensor.back_door", "off"),
+		line(0, "binary_sensor.front_door", "off"),
+		line(10, "binary_sensor.back_door", "on"),
+		line(30, "binary_sensor.front_door", "on"),
+		line(32, "binary_sensor.front_door", "off"),
+	}
+	got := mergeHistoryPlain(byEntity)
+	if len(got) != len(want) {
+		t.Fatalf("got %d lines, want %d:\n%v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("line %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
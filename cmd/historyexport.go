@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/joaobarroca93/hactl/client"
+)
+
+// resampledPoint is one bucket of a history --resample series. Value is nil
+// when the entity had no numeric state during the bucket.
+type resampledPoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	EntityID  string    `json:"entity_id"`
+	Value     *float64  `json:"value"`
+}
+
+// historyTime returns when an entry was recorded: last_updated, so that
+// attribute-only updates get their own time, or last_changed.
+func historyTime(e client.HistoryEntry) time.Time {
+	if !e.LastUpdated.IsZero() {
+		return e.LastUpdated
+	}
+	return e.LastChanged
+}
+
+// numericState parses a history state for resampling. ok is false for a
+// state that is neither a number nor unavailable/unknown, which cannot be
+// resampled; v is nil for unavailable and unknown.
+func numericState(state string) (v *float64, ok bool) {
+	switch state {
+	case "unavailable", "unknown", "":
+		return nil, true
+	}
+	n, err := strconv.ParseFloat(state, 64)
+	if err != nil {
+		return nil, false
+	}
+	return &n, true
+}
+
+// resampleHistory turns an entity's change points into a regular series
+// of buckets of size every, aligned to multiples of it, from the bucket
+// containing start up to end. Each state holds until the next one, so mean
+// is the time-weighted mean over the bucket, max the highest state in
+// effect during it, and last the state in effect when it ends.
+func resampleHistory(entityID string, entries []client.HistoryEntry, start, end time.Time, every time.Duration, agg string) ([]resampledPoint, error) {
+	type segment struct {
+		from, to time.Time
+		v        *float64
+	}
+	sorted := append([]client.HistoryEntry(nil), entries...)
+	sort.SliceStable(sorted, func(i, j int) bool { return historyTime(sorted[i]).Before(historyTime(sorted[j])) })
+	segments := make([]segment, 0, len(sorted))
+	for i, e := range sorted {
+		v, ok := numericState(e.State)
+		if !ok {
+			return nil, fmt.Errorf("--resample needs numeric states, %s has %q", entityID, e.State)
+		}
+		to := end
+		if i+1 < len(sorted) {
+			to = historyTime(sorted[i+1])
+		}
+		segments = append(segments, segment{historyTime(e), to, v})
+	}
+
+	points := []resampledPoint{}
+	first := 0
+	for b := start.Truncate(every); b.Before(end); b = b.Add(every) {
+		bEnd := b.Add(every)
+		if bEnd.After(end) {
+			bEnd = end
+		}
+		for first < len(segments) && !segments[first].to.After(b) {
+			first++
+		}
+
+		var sum, weight float64
+		var max, last *float64
+		for _, s := range segments[first:] {
+			if !s.from.Before(bEnd) {
+				break
+			}
+			from, to := s.from, s.to
+			if from.Before(b) {
+				from = b
+			}
+			if to.After(bEnd) {
+				to = bEnd
+			}
+			if !to.After(from) {
+				continue
+			}
+			last = s.v
+			if s.v == nil {
+				continue
+			}
+			d := to.Sub(from).Seconds()
+			sum += *s.v * d
+			weight += d
+			if max == nil || *s.v > *max {
+				max = s.v
+			}
+		}
+
+		p := resampledPoint{Timestamp: b, EntityID: entityID}
+		switch agg {
+		case "mean":
+			if weight > 0 {
+				mean := sum / weight
+				p.Value = &mean
+			}
+		case "max":
+			p.Value = max
+		case "last":
+			p.Value = last
+		}
+		points = append(points, p)
+	}
+	return points, nil
+}
+
+// writeHistoryCSV writes every history entry as a CSV row of timestamp,
+// entity_id, state and one column per attribute in attrs, in chronological
+// order.
+func writeHistoryCSV(w io.Writer, entityIDs []string, byEntity map[string][]client.HistoryEntry, attrs []string) error {
+	type row struct {
+		t      time.Time
+		fields []string
+	}
+	var rows []row
+	for _, id := range entityIDs {
+		for _, e := range byEntity[id] {
+			fields := []string{historyTime(e).UTC().Format(time.RFC3339Nano), id, e.State}
+			for _, a := range attrs {
+				fields = append(fields, csvValue(e.Attributes[a]))
+			}
+			rows = append(rows, row{historyTime(e), fields})
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].t.Before(rows[j].t) })
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(append([]string{"timestamp", "entity_id", "state"}, attrs...)); err != nil {
+		return err
+	}
+	for _, r := range rows {
+		if err := cw.Write(r.fields); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeResampledCSV writes resampled series as CSV rows of timestamp,
+// entity_id and state, ordered by time and then by entity. Buckets without
+// a value have an empty state.
+func writeResampledCSV(w io.Writer, points []resampledPoint) error {
+	sorted := append([]resampledPoint(nil), points...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Timestamp.Before(sorted[j].Timestamp) })
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"timestamp", "entity_id", "state"}); err != nil {
+		return err
+	}
+	for _, p := range sorted {
+		value := ""
+		if p.Value != nil {
+			value = formatNumber(*p.Value)
+		}
+		if err := cw.Write([]string{p.Timestamp.UTC().Format(time.RFC3339), p.EntityID, value}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvValue formats an attribute for a CSV cell: strings as they are,
+// missing attributes as an empty cell and anything else as JSON.
+func csvValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
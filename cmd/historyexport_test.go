@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joaobarroca93/hactl/client"
+)
+
+func TestCheckHistoryExport(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		attrs    []string
+		resample string
+		agg      string
+		aggSet   bool
+		plain    bool
+		want     time.Duration
+		wantErr  string
+	}{
+		{name: "defaults", format: "json", agg: "mean"},
+		{name: "csv with attrs", format: "csv", attrs: []string{"unit_of_measurement"}, agg: "mean"},
+		{name: "resample", format: "csv", resample: "5m", agg: "max", aggSet: true, want: 5 * time.Minute},
+		{name: "resample in days", format: "json", resample: "1d", agg: "mean", want: 24 * time.Hour},
+		{name: "bad format", format: "parquet", agg: "mean", wantErr: "--format must be json or csv"},
+		{name: "csv and plain", format: "csv", agg: "mean", plain: true, wantErr: "cannot be combined"},
+		{name: "attrs without csv", format: "json", attrs: []string{"brightness"}, agg: "mean", wantErr: "--attr needs --format csv"},
+		{name: "agg without resample", format: "csv", agg: "max", aggSet: true, wantErr: "--agg needs --resample"},
+		{name: "bad resample", format: "csv", resample: "often", agg: "mean", wantErr: "invalid --resample"},
+		{name: "zero resample", format: "csv", resample: "0s", agg: "mean", wantErr: "invalid --resample"},
+		{name: "bad agg", format: "csv", resample: "5m", agg: "median", aggSet: true, wantErr: "--agg must be one of"},
+		{name: "attrs and resample", format: "csv", attrs: []string{"brightness"}, resample: "5m", agg: "mean", wantErr: "--attr cannot be combined"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := plain
+			plain = tt.plain
+			defer func() { plain = old }()
+			got, err := checkHistoryExport(tt.format, tt.attrs, tt.resample, tt.agg, tt.aggSet)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("interval = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchHistoryAttributeChanges(t *testing.T) {
+	f := newFakeHA(t)
+	t0 := time.Date(2026, 10, 17, 18, 0, 0, 0, time.UTC)
+	for _, attributeChanges := range []bool{false, true} {
+		byEntity, err := fetchHistory([]string{"climate.living_room"}, t0, t0.Add(time.Hour), attributeChanges)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entries, ok := byEntity["climate.living_room"]; !ok || len(entries) != 0 {
+			t.Errorf("byEntity = %v, want an empty list for the entity", byEntity)
+		}
+	}
+	f.mu.Lock()
+	queries := f.historyQueries
+	f.mu.Unlock()
+	if len(queries) != 2 {
+		t.Fatalf("got %d history requests, want 2", len(queries))
+	}
+	if q := queries[0]; q.Has("significant_changes_only") {
+		t.Errorf("without attribute changes, got significant_changes_only=%q", q.Get("significant_changes_only"))
+	}
+	if q := queries[1]; q.Get("significant_changes_only") != "0" {
+		t.Errorf("with attribute changes, significant_changes_only = %q, want 0", q.Get("significant_changes_only"))
+	}
+}
+
+func TestResampleHistory(t *testing.T) {
+	t0 := time.Date(2026, 10, 17, 18, 0, 0, 0, time.UTC)
+	at := func(m int) time.Time { return t0.Add(time.Duration(m) * time.Minute) }
+	// 10 for 2m, 20 for 3m, unavailable for 5m, 30 until the end at 12m.
+	entries := []client.HistoryEntry{
+		{State: "10", LastChanged: at(0)},
+		{State: "20", LastChanged: at(2)},
+		{State: "unavailable", LastChanged: at(5)},
+		{State: "30", LastChanged: at(10)},
+	}
+	values := func(points []resampledPoint) []string {
+		out := make([]string, len(points))
+		for i, p := range points {
+			out[i] = "-"
+			if p.Value != nil {
+				out[i] = formatNumber(*p.Value)
+			}
+		}
+		return out
+	}
+	tests := []struct {
+		agg  string
+		want []string
+	}{
+		{"mean", []string{"16", "-", "30"}},
+		{"max", []string{"20", "-", "30"}},
+		{"last", []string{"20", "-", "30"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.agg, func(t *testing.T) {
+			points, err := resampleHistory("sensor.power", entries, at(1), at(12), 5*time.Minute, tt.agg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(points) != 3 {
+				t.Fatalf("got %d points, want 3", len(points))
+			}
+			for i, p := range points {
+				if !p.Timestamp.Equal(at(5*i)) || p.EntityID != "sensor.power" {
+					t.Errorf("point %d = %v %s, want %v", i, p.Timestamp, p.EntityID, at(5*i))
+				}
+			}
+			if got := values(points); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("values = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := resampleHistory("light.desk", []client.HistoryEntry{{State: "on", LastChanged: at(0)}}, at(0), at(10), time.Minute, "mean"); err == nil {
+		t.Error("non-numeric states should not be resampled")
+	}
+	points, err := resampleHistory("sensor.empty", nil, at(0), at(10), 5*time.Minute, "mean")
+	if err != nil || len(points) != 2 || points[0].Value != nil {
+		t.Errorf("an entity without history should give empty buckets, got %v, %v", points, err)
+	}
+}
+
+func TestWriteHistoryCSV(t *testing.T) {
+	t0 := time.Date(2026, 10, 17, 18, 0, 0, 0, time.UTC)
+	byEntity := map[string][]client.HistoryEntry{
+		"climate.living_room": {
+			{State: "heat", LastChanged: t0, LastUpdated: t0, Attributes: map[string]any{"current_temperature": 19.5, "hvac_action": "heating"}},
+			{State: "heat", LastChanged: t0, LastUpdated: t0.Add(10 * time.Minute), Attributes: map[string]any{"current_temperature": 20.0, "hvac_action": "idle, waiting"}},
+		},
+		"sensor.outdoor": {
+			{State: "7.5", LastChanged: t0.Add(5 * time.Minute), Attributes: map[string]any{"unit_of_measurement": "°C"}},
+		},
+	}
+	var buf bytes.Buffer
+	if err := writeHistoryCSV(&buf, []string{"climate.living_room", "sensor.outdoor"}, byEntity, []string{"current_temperature", "hvac_action"}); err != nil {
+		t.Fatal(err)
+	}
+	want := `timestamp,entity_id,state,current_temperature,hvac_action
+2026-10-17T18:00:00Z,climate.living_room,heat,19.5,heating
+2026-10-17T18:05:00Z,sensor.outdoor,7.5,,
+2026-10-17T18:10:00Z,climate.living_room,heat,20,"idle, waiting"
+`
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestWriteResampledCSV(t *testing.T) {
+	t0 := time.Date(2026, 10, 17, 18, 0, 0, 0, time.UTC)
+	v := func(f float64) *float64 { return &f }
+	points := []resampledPoint{
+		{Timestamp: t0, EntityID: "sensor.a", Value: v(1.25)},
+		{Timestamp: t0.Add(5 * time.Minute), EntityID: "sensor.a"},
+		{Timestamp: t0, EntityID: "sensor.b", Value: v(300)},
+		{Timestamp: t0.Add(5 * time.Minute), EntityID: "sensor.b", Value: v(310)},
+	}
+	var buf bytes.Buffer
+	if err := writeResampledCSV(&buf, points); err != nil {
+		t.Fatal(err)
+	}
+	want := `timestamp,entity_id,state
+2026-10-17T18:00:00Z,sensor.a,1.25
+2026-10-17T18:00:00Z,sensor.b,300
+2026-10-17T18:05:00Z,sensor.a,
+2026-10-17T18:05:00Z,sensor.b,310
+`
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// addPayloadFlags registers the flags that build a data payload:
+// --data-file, --data-json and --data.
+func addPayloadFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArray("data", nil, "key=value pair, or key:=<json> for a typed value (repeatable)")
+	cmd.Flags().String("data-json", "", "payload as a JSON object")
+	cmd.Flags().String("data-file", "", "read the payload from a YAML or JSON file (- for stdin)")
+}
+
+// readPayload merges the payload flags registered by addPayloadFlags. Later
+// sources override earlier ones key by key, in this order:
+//
+//	--data-file < --data-json < --data
+//
+// Callers apply their own convenience flags on top.
+func readPayload(cmd *cobra.Command) (map[string]any, error) {
+	data := map[string]any{}
+
+	if file, _ := cmd.Flags().GetString("data-file"); file != "" {
+		raw, err := readFileOrStdin(file)
+		if err != nil {
+			return nil, fmt.Errorf("read --data-file: %s", err)
+		}
+		var fromFile map[string]any
+		if err := yaml.Unmarshal(raw, &fromFile); err != nil {
+			return nil, fmt.Errorf("--data-file must contain a YAML or JSON mapping: %s", err)
+		}
+		for k, v := range fromFile {
+			data[k] = v
+		}
+	}
+
+	if src, _ := cmd.Flags().GetString("data-json"); src != "" {
+		var fromJSON map[string]any
+		if err := json.Unmarshal([]byte(src), &fromJSON); err != nil {
+			return nil, fmt.Errorf("--data-json must be a JSON object: %s", err)
+		}
+		for k, v := range fromJSON {
+			data[k] = v
+		}
+	}
+
+	dataFlags, _ := cmd.Flags().GetStringArray("data")
+	for _, kv := range dataFlags {
+		k, v, err := parseDataFlag(kv)
+		if err != nil {
+			return nil, err
+		}
+		data[k] = v
+	}
+	return data, nil
+}
+
+// parseDataFlag parses one --data value. key=value values go through
+// parseValue; key:=value values are decoded as JSON, for lists, objects and
+// strings that would otherwise be read as numbers.
+func parseDataFlag(kv string) (string, any, error) {
+	k, v, found := strings.Cut(kv, "=")
+	if !found || k == "" || k == ":" {
+		return "", nil, fmt.Errorf("--data must be in key=value or key:=json format, got: %s", kv)
+	}
+	if key, typed := strings.CutSuffix(k, ":"); typed {
+		var val any
+		if err := json.Unmarshal([]byte(v), &val); err != nil {
+			return "", nil, fmt.Errorf("--data %s: invalid JSON value %s: %s", key, v, err)
+		}
+		return key, val, nil
+	}
+	return k, parseValue(v), nil
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestParseDataFlag(t *testing.T) {
+	tests := []struct {
+		in      string
+		key     string
+		want    any
+		wantErr string
+	}{
+		{"brightness=80", "brightness", int64(80), ""},
+		{"message=hello world", "message", "hello world", ""},
+		{"code:=\"0123\"", "code", "0123", ""},
+		{"rgb_color:=[255,0,0]", "rgb_color", []any{255.0, 0.0, 0.0}, ""},
+		{`data:={"tag":"x"}`, "data", map[string]any{"tag": "x"}, ""},
+		{"flag:=true", "flag", true, ""},
+		{"novalue", "", nil, "key=value or key:=json"},
+		{":=1", "", nil, "key=value or key:=json"},
+		{"list:=[1,", "", nil, "invalid JSON value"},
+	}
+	for _, tt := range tests {
+		k, v, err := parseDataFlag(tt.in)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseDataFlag(%q) error = %v, want %q", tt.in, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || k != tt.key || !reflect.DeepEqual(v, tt.want) {
+			t.Errorf("parseDataFlag(%q) = %q, %#v, %v; want %q, %#v", tt.in, k, v, err, tt.key, tt.want)
+		}
+	}
+}
+
+func TestReadPayloadPrecedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "payload.yaml")
+	yaml := "title: from file\nmessage: from file\npriority: 1\ndata:\n  tag: door\n"
+	if err := os.WriteFile(file, []byte(yaml), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := &cobra.Command{}
+	addPayloadFlags(cmd)
+	cmd.Flags().Set("data-file", file)
+	cmd.Flags().Set("data-json", `{"message": "from json", "priority": 2}`)
+	cmd.Flags().Set("data", "priority=3")
+
+	got, err := readPayload(cmd)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]any{
+		"title":    "from file",
+		"message":  "from json",
+		"priority": int64(3),
+		"data":     map[string]any{"tag": "door"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readPayload() = %#v, want %#v", got, want)
+	}
+}
+
+func TestReadPayloadErrors(t *testing.T) {
+	for _, tt := range []struct {
+		flag, value, want string
+	}{
+		{"data-json", `[1, 2]`, "must be a JSON object"},
+		{"data-file", "/nonexistent/payload.yaml", "read --data-file"},
+	} {
+		cmd := &cobra.Command{}
+		addPayloadFlags(cmd)
+		cmd.Flags().Set(tt.flag, tt.value)
+		if _, err := readPayload(cmd); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("--%s %s: got %v, want %q", tt.flag, tt.value, err, tt.want)
+		}
+	}
+}
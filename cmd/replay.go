@@ -0,0 +1,341 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/joaobarroca93/hactl/output"
+	"github.com/spf13/cobra"
+)
+
+// recordedFrame is one line of an events watch --record file: a raw
+// WebSocket event frame and when it was received.
+type recordedFrame struct {
+	Time  time.Time       `json:"time"`
+	Frame json.RawMessage `json:"frame"`
+}
+
+var eventsReplayCmd = &cobra.Command{
+	Use:   "replay <file>",
+	Short: "Replay events recorded with events watch --record",
+	Long: `Re-emit events recorded with events watch --record, with their original
+timing, in the same format as events watch.
+
+--speed scales the timing: 10x plays ten times faster, 0.5x at half speed,
+and max without any delay.
+
+--serve starts a fake Home Assistant WebSocket server on an address, e.g.
+localhost:8124, and replays the events to whatever subscribes to them
+(subscribe_events, any token), so consumers can be tested against a
+deterministic event stream. Replay starts with the first subscription and
+the connections are closed when it ends.
+
+Examples:
+  hactl events replay door.ndjson
+  hactl events replay door.ndjson --speed 10x --plain
+  hactl events replay door.ndjson --speed max --serve localhost:8124`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		speedFlag, _ := cmd.Flags().GetString("speed")
+		speed, err := parseSpeed(speedFlag)
+		if err != nil {
+			return output.Err("%s", err)
+		}
+		data, err := readFileOrStdin(args[0])
+		if err != nil {
+			return output.Err("read recording: %s", err)
+		}
+		frames, err := parseRecording(data)
+		if err != nil {
+			return output.Err("%s", err)
+		}
+
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+		defer signal.Stop(sigCh)
+
+		var srv *replayServer
+		if addr, _ := cmd.Flags().GetString("serve"); addr != "" {
+			srv, err = startReplayServer(addr)
+			if err != nil {
+				return output.Err("%s", err)
+			}
+			defer srv.close()
+			if !quiet {
+				fmt.Fprintf(os.Stderr, "serving on ws://%s/api/websocket, waiting for a subscription...\n", srv.addr)
+			}
+			select {
+			case <-srv.ready:
+			case <-sigCh:
+				return nil
+			}
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+		emit := func(raw []byte) {
+			if srv != nil {
+				srv.broadcast(raw)
+			}
+			if quiet {
+				return
+			}
+			var msg map[string]any
+			if err := json.Unmarshal(raw, &msg); err != nil {
+				return
+			}
+			event, _ := msg["event"].(map[string]any)
+			if event == nil {
+				return
+			}
+			if plain {
+				fmt.Println(formatEventPlain(event))
+			} else {
+				_ = enc.Encode(event)
+			}
+		}
+
+		for i, f := range frames {
+			if i > 0 {
+				select {
+				case <-time.After(replayDelay(frames[i-1].Time, f.Time, speed)):
+				case <-sigCh:
+					return nil
+				}
+			}
+			emit(f.Frame)
+		}
+		return nil
+	},
+}
+
+func init() {
+	eventsReplayCmd.Flags().String("speed", "1x", "playback speed, e.g. 10x or 0.5x; max plays without delays")
+	eventsReplayCmd.Flags().String("serve", "", "also replay to clients of a fake Home Assistant WebSocket server on this address")
+
+	eventsCmd.AddCommand(eventsReplayCmd)
+}
+
+// parseSpeed parses a --speed value: a positive factor with an optional x
+// suffix, or "max" (returned as 0) for no delays.
+func parseSpeed(s string) (float64, error) {
+	if s == "max" {
+		return 0, nil
+	}
+	n, err := strconv.ParseFloat(strings.TrimSuffix(s, "x"), 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid --speed %q: use a positive factor such as 10x or 0.5x, or max", s)
+	}
+	return n, nil
+}
+
+// replayDelay returns how long to wait between frames recorded at prev and
+// cur when playing at speed (0 means no delay).
+func replayDelay(prev, cur time.Time, speed float64) time.Duration {
+	if speed == 0 || !cur.After(prev) {
+		return 0
+	}
+	return time.Duration(float64(cur.Sub(prev)) / speed)
+}
+
+// parseRecording parses an events watch --record file. Blank lines are
+// skipped.
+func parseRecording(data []byte) ([]recordedFrame, error) {
+	var frames []recordedFrame
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	line := 0
+	for sc.Scan() {
+		line++
+		text := bytes.TrimSpace(sc.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var f recordedFrame
+		if err := json.Unmarshal(text, &f); err != nil || len(f.Frame) == 0 {
+			return nil, fmt.Errorf("line %d: not a recorded event frame", line)
+		}
+		frames = append(frames, f)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read recording: %s", err)
+	}
+	return frames, nil
+}
+
+// eventRecorder writes the frames events watch receives to a --record file.
+type eventRecorder struct {
+	f   *os.File
+	enc *json.Encoder
+}
+
+func newEventRecorder(path string) (*eventRecorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("create --record file: %w", err)
+	}
+	return &eventRecorder{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+// record appends raw with the current time. A nil recorder records nothing.
+func (r *eventRecorder) record(raw []byte) error {
+	if r == nil {
+		return nil
+	}
+	return r.enc.Encode(recordedFrame{Time: time.Now().UTC(), Frame: raw})
+}
+
+func (r *eventRecorder) close() error {
+	if r == nil {
+		return nil
+	}
+	return r.f.Close()
+}
+
+// replayServer is a minimal stand-in for the Home Assistant WebSocket API:
+// it accepts any token, acknowledges every command, and delivers broadcast
+// events to matching subscribe_events subscriptions.
+type replayServer struct {
+	addr     string
+	listener net.Listener
+	ready    chan struct{}
+
+	mu        sync.Mutex
+	conns     map[*replayConn]bool
+	readyOnce sync.Once
+}
+
+type replayConn struct {
+	conn *websocket.Conn
+
+	mu   sync.Mutex
+	subs map[int]string // subscription id -> event type ("" for all)
+}
+
+func startReplayServer(addr string) (*replayServer, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("--serve: %w", err)
+	}
+	s := &replayServer{
+		addr:     l.Addr().String(),
+		listener: l,
+		ready:    make(chan struct{}),
+		conns:    map[*replayConn]bool{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/websocket", s.handle)
+	go http.Serve(l, mux)
+	return s, nil
+}
+
+func (s *replayServer) handle(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c := &replayConn{conn: conn, subs: map[int]string{}}
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	if c.write(map[string]any{"type": "auth_required", "ha_version": "hactl-replay"}) != nil {
+		return
+	}
+	var auth map[string]any
+	if conn.ReadJSON(&auth) != nil || auth["type"] != "auth" {
+		return
+	}
+	if c.write(map[string]any{"type": "auth_ok", "ha_version": "hactl-replay"}) != nil {
+		return
+	}
+	s.mu.Lock()
+	s.conns[c] = true
+	s.mu.Unlock()
+
+	for {
+		var msg struct {
+			ID        int    `json:"id"`
+			Type      string `json:"type"`
+			EventType string `json:"event_type"`
+		}
+		if conn.ReadJSON(&msg) != nil {
+			return
+		}
+		if msg.Type == "subscribe_events" {
+			c.mu.Lock()
+			c.subs[msg.ID] = msg.EventType
+			c.mu.Unlock()
+		}
+		if c.write(map[string]any{"id": msg.ID, "type": "result", "success": true, "result": nil}) != nil {
+			return
+		}
+		if msg.Type == "subscribe_events" {
+			s.readyOnce.Do(func() { close(s.ready) })
+		}
+	}
+}
+
+func (c *replayConn) write(v any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn.WriteJSON(v)
+}
+
+// broadcast sends a recorded frame to every subscription whose event type
+// matches, with the frame's id replaced by the subscription's.
+func (s *replayServer) broadcast(raw []byte) {
+	var frame map[string]any
+	if err := json.Unmarshal(raw, &frame); err != nil {
+		return
+	}
+	event, _ := frame["event"].(map[string]any)
+	eventType, _ := event["event_type"].(string)
+
+	s.mu.Lock()
+	conns := make([]*replayConn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.mu.Unlock()
+
+	for _, c := range conns {
+		c.mu.Lock()
+		for id, t := range c.subs {
+			if t != "" && t != eventType {
+				continue
+			}
+			frame["id"] = id
+			_ = c.conn.WriteJSON(frame)
+		}
+		c.mu.Unlock()
+	}
+}
+
+// close stops accepting clients and closes the open connections.
+func (s *replayServer) close() {
+	s.listener.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		c.mu.Lock()
+		c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "replay finished"))
+		c.mu.Unlock()
+		c.conn.Close()
+	}
+}
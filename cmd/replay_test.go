@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joaobarroca93/hactl/client"
+)
+
+func TestParseSpeed(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+		err  bool
+	}{
+		{"1x", 1, false},
+		{"10x", 10, false},
+		{"0.5x", 0.5, false},
+		{"3", 3, false},
+		{"max", 0, false},
+		{"0x", 0, true},
+		{"-2x", 0, true},
+		{"fast", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseSpeed(tt.in)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("parseSpeed(%q) = %v, %v; want %v (error %v)", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestReplayDelay(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		cur   time.Time
+		speed float64
+		want  time.Duration
+	}{
+		{t0.Add(10 * time.Second), 1, 10 * time.Second},
+		{t0.Add(10 * time.Second), 10, time.Second},
+		{t0.Add(10 * time.Second), 0.5, 20 * time.Second},
+		{t0.Add(10 * time.Second), 0, 0},
+		{t0.Add(-time.Second), 1, 0},
+	}
+	for _, tt := range tests {
+		if got := replayDelay(t0, tt.cur, tt.speed); got != tt.want {
+			t.Errorf("replayDelay(+%s, %v) = %s, want %s", tt.cur.Sub(t0), tt.speed, got, tt.want)
+		}
+	}
+}
+
+func TestRecordingRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	r, err := newEventRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames := []string{
+		`{"id":1,"type":"event","event":{"event_type":"state_changed"}}`,
+		`{"id":1,"type":"event","event":{"event_type":"call_service"}}`,
+	}
+	for _, f := range frames {
+		if err := r.record([]byte(f)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r.close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := parseRecording(append(data, '\n'))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || string(got[0].Frame) != frames[0] || string(got[1].Frame) != frames[1] {
+		t.Fatalf("parseRecording() = %+v", got)
+	}
+	if got[1].Time.Before(got[0].Time) || got[0].Time.IsZero() {
+		t.Errorf("times out of order: %v, %v", got[0].Time, got[1].Time)
+	}
+
+	if _, err := parseRecording([]byte("{\"time\":\"2026-01-01T00:00:00Z\",\"frame\":{}}\nnot json\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected a line 2 error, got %v", err)
+	}
+}
+
+func TestReplayServer(t *testing.T) {
+	srv, err := startReplayServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.close()
+
+	ws, err := client.NewWS("http://"+srv.addr, "any-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess := ws.StartSession()
+	defer sess.Close()
+	sub, err := sess.SubscribeEvents("state_changed")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-srv.ready:
+	case <-time.After(2 * time.Second):
+		t.Fatal("server never became ready")
+	}
+	srv.broadcast([]byte(`{"id":7,"type":"event","event":{"event_type":"call_service"}}`))
+	srv.broadcast([]byte(`{"id":7,"type":"event","event":{"event_type":"state_changed","data":{"entity_id":"light.desk"}}}`))
+
+	select {
+	case raw := <-sub.C:
+		var frame struct {
+			ID    int          `json:"id"`
+			Event client.Event `json:"event"`
+		}
+		if err := json.Unmarshal(raw, &frame); err != nil {
+			t.Fatal(err)
+		}
+		if frame.ID != sub.ID || frame.Event.EventType != "state_changed" {
+			t.Errorf("got frame %s", raw)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event delivered")
+	}
+
+	srv.close()
+	select {
+	case <-sess.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("connection not closed after the replay")
+	}
+}
@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/joaobarroca93/hactl/client"
+)
+
+// entityIDRe matches strings shaped like an entity ID (domain.object_id).
+var entityIDRe = regexp.MustCompile(`^[a-z0-9_]+\.[a-z0-9_]+$`)
+
+// maxSuggestions caps the candidate list shown for ambiguous references.
+const maxSuggestions = 10
+
+// resolverStates caches the filtered state list for the lifetime of the
+// process, so resolving several references costs one request.
+var resolverStates []client.State
+
+// resolveEntity maps a user-supplied reference to an entity ID. ref may be an
+// entity ID, an object ID when domain is given ("shopping_list" for todo), or
+// a friendly name, matched exactly or fuzzily ("kitchen light").
+//
+// Only entities visible through the filter are ever considered, and hidden
+// entities produce the same "entity not found" error as missing ones.
+// When domain is non-empty, only entities of that domain are candidates.
+func resolveEntity(ref, domain string) (string, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return "", fmt.Errorf("entity is required")
+	}
+
+	// Fast path: an entity ID that the filter allows needs no lookup. An
+	// object ID with the domain prepended qualifies only in exposed mode,
+	// where the allowlist proves the entity exists.
+	id := ref
+	if domain != "" && !strings.Contains(ref, ".") {
+		id = domain + "." + ref
+	}
+	if entityIDRe.MatchString(id) && entityFilter.IsAllowed(id) && (id == ref || entityFilter.Mode() == "exposed") {
+		if domain == "" || strings.HasPrefix(id, domain+".") {
+			return id, nil
+		}
+	}
+
+	states, err := filteredStates()
+	if err != nil {
+		return "", err
+	}
+	candidates := states
+	if domain != "" {
+		candidates = nil
+		for _, s := range states {
+			if strings.HasPrefix(s.EntityID, domain+".") {
+				candidates = append(candidates, s)
+			}
+		}
+	}
+
+	matches := matchEntities(ref, candidates)
+	if entityIDRe.MatchString(ref) {
+		// An explicit entity ID is never silently swapped for a similar
+		// one; near matches are only suggested.
+		return "", notFoundError(ref, matches)
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("entity not found: %s", ref)
+	case 1:
+		return matches[0].EntityID, nil
+	}
+	return "", ambiguousError(ref, matches)
+}
+
+// filteredStates returns the states visible through the filter, fetching
+// them on first use.
+func filteredStates() ([]client.State, error) {
+	if resolverStates == nil {
+		states, err := getClient().ListStates()
+		if err != nil {
+			return nil, err
+		}
+		resolverStates = entityFilter.FilterStates(states)
+	}
+	return resolverStates, nil
+}
+
+// matchEntities returns the states ref refers to, trying progressively
+// looser rules and stopping at the first rule that matches anything:
+// exact entity ID, exact friendly name, exact object ID, then fuzzy (every
+// word of ref appears in the friendly name or entity ID).
+func matchEntities(ref string, states []client.State) []client.State {
+	lower := strings.ToLower(ref)
+	rules := []func(s client.State) bool{
+		func(s client.State) bool { return s.EntityID == lower },
+		func(s client.State) bool { return strings.EqualFold(entityName(s), ref) },
+		func(s client.State) bool {
+			_, objectID, _ := strings.Cut(s.EntityID, ".")
+			return objectID == normalizeRef(ref, "_")
+		},
+		func(s client.State) bool {
+			haystack := normalizeRef(entityName(s)+" "+s.EntityID, " ")
+			for _, word := range strings.Fields(normalizeRef(ref, " ")) {
+				if !strings.Contains(haystack, word) {
+					return false
+				}
+			}
+			return true
+		},
+	}
+	for _, rule := range rules {
+		var out []client.State
+		for _, s := range states {
+			if rule(s) {
+				out = append(out, s)
+			}
+		}
+		if len(out) > 0 {
+			sort.Slice(out, func(i, j int) bool { return out[i].EntityID < out[j].EntityID })
+			return out
+		}
+	}
+	return nil
+}
+
+// entityName returns the friendly name of s, or "" if it has none.
+func entityName(s client.State) string {
+	name, _ := s.Attributes["friendly_name"].(string)
+	return name
+}
+
+// normalizeRef lowercases s and replaces every run of non-alphanumeric
+// characters with sep.
+func normalizeRef(s, sep string) string {
+	var b strings.Builder
+	pending := false
+	for _, r := range strings.ToLower(s) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r > 127 {
+			if pending && b.Len() > 0 {
+				b.WriteString(sep)
+			}
+			pending = false
+			b.WriteRune(r)
+			continue
+		}
+		pending = true
+	}
+	return b.String()
+}
+
+func ambiguousError(ref string, matches []client.State) error {
+	return fmt.Errorf("%q is ambiguous; did you mean one of:\n%s", ref, suggestionLines(matches))
+}
+
+func notFoundError(ref string, matches []client.State) error {
+	if len(matches) == 0 {
+		return fmt.Errorf("entity not found: %s", ref)
+	}
+	return fmt.Errorf("entity not found: %s\n  did you mean:\n%s", ref, suggestionLines(matches))
+}
+
+func suggestionLines(matches []client.State) string {
+	lines := make([]string, 0, maxSuggestions+1)
+	for i, m := range matches {
+		if i == maxSuggestions {
+			lines = append(lines, fmt.Sprintf("    … and %d more", len(matches)-maxSuggestions))
+			break
+		}
+		if name := entityName(m); name != "" {
+			lines = append(lines, fmt.Sprintf("    %s (%s)", m.EntityID, name))
+		} else {
+			lines = append(lines, "    "+m.EntityID)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
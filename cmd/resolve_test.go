@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/joaobarroca93/hactl/client"
+	"github.com/joaobarroca93/hactl/filter"
+)
+
+// useFilter sets entityFilter for the rest of the test.
+func useFilter(t *testing.T, f *filter.Filter) {
+	t.Helper()
+	old := entityFilter
+	entityFilter = f
+	t.Cleanup(func() { entityFilter = old })
+}
+
+// useResolverStates makes the resolver see states for the rest of the test.
+func useResolverStates(t *testing.T, states []client.State) {
+	t.Helper()
+	old := resolverStates
+	resolverStates = states
+	t.Cleanup(func() { resolverStates = old })
+}
+
+func resolverFixture() []client.State {
+	named := func(id, name string) client.State {
+		return client.State{EntityID: id, Attributes: map[string]any{"friendly_name": name}}
+	}
+	return []client.State{
+		named("light.shelly_abc123_channel_1", "Kitchen Light"),
+		named("light.kitchen_cabinets", "Kitchen Cabinets"),
+		named("light.bedroom", "Bedroom Lamp"),
+		named("switch.kitchen_light", "Kitchen Light Switch"),
+		{EntityID: "sensor.outdoor_temperature"},
+	}
+}
+
+// --- matchEntities ---
+
+func TestMatchEntities(t *testing.T) {
+	states := resolverFixture()
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"light.bedroom", "light.bedroom"},
+		{"kitchen light", "light.shelly_abc123_channel_1"},
+		{"KITCHEN LIGHT", "light.shelly_abc123_channel_1"},
+		{"bedroom lamp", "light.bedroom"},
+		{"kitchen_cabinets", "light.kitchen_cabinets"},
+		{"cabinets", "light.kitchen_cabinets"},
+		{"outdoor temp", "sensor.outdoor_temperature"},
+		{"kitchen", "light.kitchen_cabinets,light.shelly_abc123_channel_1,switch.kitchen_light"},
+		{"garage", ""},
+	}
+	for _, tt := range tests {
+		got := ids(matchEntities(tt.ref, states))
+		if got != tt.want {
+			t.Errorf("matchEntities(%q) = %q, want %q", tt.ref, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeRef(t *testing.T) {
+	if got := normalizeRef("  Kitchen -- Light ", "_"); got != "kitchen_light" {
+		t.Errorf("normalizeRef() = %q", got)
+	}
+}
+
+// --- resolveEntity ---
+
+func TestResolveEntity(t *testing.T) {
+	useFilter(t, filter.New("all", true))
+	useResolverStates(t, resolverFixture())
+
+	tests := []struct {
+		ref, domain string
+		want        string
+		errContains string
+	}{
+		{"light.bedroom", "", "light.bedroom", ""},
+		{"kitchen light", "light", "light.shelly_abc123_channel_1", ""},
+		{"kitchen light", "switch", "switch.kitchen_light", ""},
+		{"Kitchen Light Switch", "", "switch.kitchen_light", ""},
+		{"kitchen", "light", "", "ambiguous"},
+		{"garage door", "", "", "entity not found"},
+		{"", "", "", "entity is required"},
+		{"  ", "todo", "", "entity is required"},
+	}
+	for _, tt := range tests {
+		got, err := resolveEntity(tt.ref, tt.domain)
+		if tt.errContains != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("resolveEntity(%q, %q) error = %v, want %q", tt.ref, tt.domain, err, tt.errContains)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("resolveEntity(%q, %q) = %q, %v; want %q", tt.ref, tt.domain, got, err, tt.want)
+		}
+	}
+}
+
+func TestResolveEntityNeverSwapsIDs(t *testing.T) {
+	// In exposed mode, resolverStates only ever holds filtered entities.
+	useFilter(t, filter.New("exposed", true))
+	useResolverStates(t, resolverFixture())
+
+	_, err := resolveEntity("light.kitchen", "")
+	if err == nil {
+		t.Fatal("an unknown entity ID must not resolve to a similar entity")
+	}
+	if !strings.Contains(err.Error(), "did you mean") {
+		t.Errorf("expected suggestions, got: %v", err)
+	}
+}
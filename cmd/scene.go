@@ -0,0 +1,295 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/joaobarroca93/hactl/client"
+	"github.com/joaobarroca93/hactl/output"
+	"github.com/spf13/cobra"
+)
+
+// CapturedScene records a scene hactl created with scene.create. The scene
+// entity itself is never exposed, so this record is what allows restoring
+// it: its entities were checked against the filter when it was captured.
+type CapturedScene struct {
+	Name       string    `json:"name"`
+	EntityID   string    `json:"entity_id"`
+	Entities   []string  `json:"entities"`
+	CapturedAt time.Time `json:"captured_at"`
+}
+
+var sceneNameRe = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+var sceneCmd = &cobra.Command{
+	Use:   "scene",
+	Short: "Capture and restore entity states with scenes",
+}
+
+var sceneCaptureCmd = &cobra.Command{
+	Use:   "capture <entity>...",
+	Short: "Capture the current state of entities into a scene",
+	Long: `Capture the current state of entities into a Home Assistant scene, using
+scene.create with snapshot_entities. Restore it later with scene restore.
+
+Scenes captured this way live in Home Assistant's memory only and are lost
+when it restarts. hactl keeps a record of them in ~/.config/hactl/scenes.json.
+
+Examples:
+  hactl scene capture light.living_room light.kitchen --name before_doorbell
+  hactl scene capture 'light.*' --name lights`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, _ := cmd.Flags().GetString("name")
+		if name == "" {
+			return output.Err("--name is required")
+		}
+		entities, err := resolveServiceEntities(args, "")
+		if err != nil {
+			return output.Err("%s", err)
+		}
+		rec, err := captureScene(name, entities)
+		if err != nil {
+			return output.Err("%s", err)
+		}
+		if quiet {
+			return nil
+		}
+		if plain {
+			output.PrintPlain(fmt.Sprintf("captured %d entities to %s", len(rec.Entities), rec.EntityID))
+			return nil
+		}
+		return output.PrintJSON(rec)
+	},
+}
+
+var sceneRestoreCmd = &cobra.Command{
+	Use:   "restore <name>",
+	Short: "Restore a scene captured with scene capture",
+	Long: `Restore the entities of a scene captured with scene capture and print
+their resulting states. Only scenes captured by hactl can be restored.
+
+Examples:
+  hactl scene restore before_doorbell
+  hactl scene restore before_doorbell --delete`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		del, _ := cmd.Flags().GetBool("delete")
+		rec, err := findCapturedScene(args[0])
+		if err != nil {
+			return output.Err("%s", err)
+		}
+		states, err := restoreScene(rec)
+		if err != nil {
+			return output.Err("%s", err)
+		}
+		if del {
+			if err := deleteScene(rec); err != nil {
+				return output.Err("%s", err)
+			}
+		}
+		if quiet {
+			return nil
+		}
+		if plain {
+			for _, s := range states {
+				output.PrintPlain(fmt.Sprintf("%s: %s", s.EntityID, s.State))
+			}
+			return nil
+		}
+		return output.PrintJSON(states)
+	},
+}
+
+var sceneListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List scenes captured with scene capture",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		scenes, err := loadCapturedScenes()
+		if err != nil {
+			return output.Err("%s", err)
+		}
+		list := make([]CapturedScene, 0, len(scenes))
+		for _, rec := range scenes {
+			list = append(list, rec)
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].CapturedAt.Before(list[j].CapturedAt) })
+		if quiet {
+			return nil
+		}
+		if plain {
+			for _, rec := range list {
+				output.PrintPlain(fmt.Sprintf("%s: %d entities at %s", rec.Name, len(rec.Entities), rec.CapturedAt.Local().Format("2006-01-02 15:04")))
+			}
+			return nil
+		}
+		return output.PrintJSON(list)
+	},
+}
+
+var sceneDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Delete a scene captured with scene capture",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rec, err := findCapturedScene(args[0])
+		if err != nil {
+			return output.Err("%s", err)
+		}
+		if err := deleteScene(rec); err != nil {
+			return output.Err("%s", err)
+		}
+		if quiet {
+			return nil
+		}
+		if plain {
+			output.PrintPlain("deleted " + rec.EntityID)
+			return nil
+		}
+		return output.PrintJSON(map[string]string{"deleted": rec.Name})
+	},
+}
+
+func init() {
+	sceneCaptureCmd.Flags().String("name", "", "scene name (lowercase letters, digits and _)")
+	sceneRestoreCmd.Flags().Bool("delete", false, "delete the scene after restoring it")
+
+	sceneCmd.AddCommand(sceneCaptureCmd)
+	sceneCmd.AddCommand(sceneRestoreCmd)
+	sceneCmd.AddCommand(sceneListCmd)
+	sceneCmd.AddCommand(sceneDeleteCmd)
+	rootCmd.AddCommand(sceneCmd)
+}
+
+// captureScene snapshots entities into scene.<name> and records it.
+func captureScene(name string, entities []string) (*CapturedScene, error) {
+	if !sceneNameRe.MatchString(name) {
+		return nil, fmt.Errorf("invalid scene name %q: use lowercase letters, digits and _", name)
+	}
+	if len(entities) == 0 {
+		return nil, fmt.Errorf("no entities to capture")
+	}
+	_, err := getClient().CallService("scene", "create", map[string]any{
+		"scene_id":          name,
+		"snapshot_entities": entities,
+	})
+	if err != nil {
+		return nil, err
+	}
+	rec := CapturedScene{
+		Name:       name,
+		EntityID:   "scene." + name,
+		Entities:   entities,
+		CapturedAt: time.Now().UTC(),
+	}
+	scenes, err := loadCapturedScenes()
+	if err != nil {
+		return nil, err
+	}
+	scenes[name] = rec
+	if err := saveCapturedScenes(scenes); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// restoreScene activates a captured scene and returns the states of its
+// entities once they have settled.
+func restoreScene(rec *CapturedScene) ([]client.State, error) {
+	if _, err := getClient().GetState(rec.EntityID); err != nil {
+		if strings.HasPrefix(err.Error(), "entity not found") {
+			return nil, fmt.Errorf("%s no longer exists in Home Assistant (captured scenes do not survive a restart)", rec.EntityID)
+		}
+		return nil, err
+	}
+	ws, err := dialWS()
+	if err != nil {
+		return nil, err
+	}
+	sess := ws.StartSession()
+	defer sess.Close()
+	return callAndSettle(sess, "scene", "turn_on", map[string]any{"entity_id": rec.EntityID}, rec.Entities, defaultSettleWait)
+}
+
+// deleteScene removes a captured scene from Home Assistant and from the
+// local record. A scene HA has already forgotten is only dropped locally.
+func deleteScene(rec *CapturedScene) error {
+	if _, err := getClient().GetState(rec.EntityID); err == nil {
+		if _, err := getClient().CallService("scene", "delete", map[string]any{"entity_id": rec.EntityID}); err != nil {
+			return err
+		}
+	} else if !strings.HasPrefix(err.Error(), "entity not found") {
+		return err
+	}
+	scenes, err := loadCapturedScenes()
+	if err != nil {
+		return err
+	}
+	delete(scenes, rec.Name)
+	return saveCapturedScenes(scenes)
+}
+
+func findCapturedScene(name string) (*CapturedScene, error) {
+	scenes, err := loadCapturedScenes()
+	if err != nil {
+		return nil, err
+	}
+	rec, ok := scenes[name]
+	if !ok {
+		return nil, fmt.Errorf("scene not found: %s (only scenes captured with hactl scene capture can be used)", name)
+	}
+	return &rec, nil
+}
+
+// capturedScenesPath returns the file recording captured scenes.
+func capturedScenesPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot determine home directory: %w", err)
+	}
+	return filepath.Join(home, ".config", "hactl", "scenes.json"), nil
+}
+
+func loadCapturedScenes() (map[string]CapturedScene, error) {
+	path, err := capturedScenesPath()
+	if err != nil {
+		return nil, err
+	}
+	scenes := map[string]CapturedScene{}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return scenes, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read captured scenes: %w", err)
+	}
+	if err := json.Unmarshal(data, &scenes); err != nil {
+		return nil, fmt.Errorf("parse captured scenes: %w", err)
+	}
+	return scenes, nil
+}
+
+func saveCapturedScenes(scenes map[string]CapturedScene) error {
+	path, err := capturedScenesPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("create config directory: %w", err)
+	}
+	data, err := json.Marshal(scenes)
+	if err != nil {
+		return fmt.Errorf("marshal captured scenes: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("write captured scenes: %w", err)
+	}
+	return nil
+}
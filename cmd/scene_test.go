@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCapturedScenesRoundTrip(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	scenes, err := loadCapturedScenes()
+	if err != nil || len(scenes) != 0 {
+		t.Fatalf("empty store: got %v, %v", scenes, err)
+	}
+	scenes["before_doorbell"] = CapturedScene{
+		Name:       "before_doorbell",
+		EntityID:   "scene.before_doorbell",
+		Entities:   []string{"light.kitchen"},
+		CapturedAt: time.Now().UTC(),
+	}
+	if err := saveCapturedScenes(scenes); err != nil {
+		t.Fatal(err)
+	}
+	rec, err := findCapturedScene("before_doorbell")
+	if err != nil || rec.EntityID != "scene.before_doorbell" || rec.Entities[0] != "light.kitchen" {
+		t.Errorf("findCapturedScene() = %+v, %v", rec, err)
+	}
+	if _, err := findCapturedScene("scene.movie_night"); err == nil || !strings.Contains(err.Error(), "only scenes captured") {
+		t.Errorf("expected not found error, got %v", err)
+	}
+}
+
+func TestCaptureSceneValidation(t *testing.T) {
+	for _, name := range []string{"Before", "before-doorbell", "scene.x", ""} {
+		if _, err := captureScene(name, []string{"light.kitchen"}); err == nil || !strings.Contains(err.Error(), "invalid scene name") {
+			t.Errorf("captureScene(%q): got %v, want invalid name", name, err)
+		}
+	}
+	if _, err := captureScene("ok", nil); err == nil {
+		t.Error("captureScene with no entities should fail")
+	}
+}
+
+func TestRunRestoring(t *testing.T) {
+	for _, tt := range []struct {
+		script string
+		want   int
+	}{
+		{"exit 0", 0},
+		{"exit 3", 3},
+	} {
+		code, err := runRestoring([]string{"sh", "-c", tt.script})
+		if err != nil || code != tt.want {
+			t.Errorf("runRestoring(%q) = %d, %v; want %d", tt.script, code, err, tt.want)
+		}
+	}
+	if _, err := runRestoring([]string{"/nonexistent/command"}); err == nil {
+		t.Error("expected an error for a missing command")
+	}
+}
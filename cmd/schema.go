@@ -0,0 +1,303 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/joaobarroca93/hactl/client"
+)
+
+// targetKeys are accepted by every entity service in addition to its
+// declared fields.
+var targetKeys = map[string]bool{
+	"entity_id": true,
+	"device_id": true,
+	"area_id":   true,
+	"floor_id":  true,
+	"label_id":  true,
+}
+
+// openServiceDomains accept arbitrary keys regardless of their declared
+// fields (script variables, python_script arguments).
+var openServiceDomains = map[string]bool{
+	"script":        true,
+	"python_script": true,
+}
+
+// validateServiceData checks a service call payload against schema before it
+// is sent. userData holds the keys the user supplied directly (--data,
+// --data-json, --data-file); only those are checked for unknown names and
+// value types, since hactl's own convenience flags are known to be
+// well-formed. payload is the complete data and is used to check required
+// fields.
+func validateServiceData(schema *client.ServiceSchema, userData, payload map[string]any) error {
+	svc := schema.Domain + "." + schema.Service
+	keys := make([]string, 0, len(userData))
+	for k := range userData {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if targetKeys[k] {
+			continue
+		}
+		field, ok := schema.Fields[k]
+		if !ok {
+			if len(schema.Fields) == 0 || openServiceDomains[schema.Domain] {
+				continue
+			}
+			return fmt.Errorf("unknown field %q for %s\n  valid fields: %s", k, svc, strings.Join(sortedFieldNames(schema), ", "))
+		}
+		if err := checkSelectorValue(field.Selector, userData[k]); err != nil {
+			return fmt.Errorf("invalid value for %s: %s", k, err)
+		}
+	}
+
+	for _, name := range sortedFieldNames(schema) {
+		if schema.Fields[name].Required {
+			if _, ok := payload[name]; !ok {
+				return fmt.Errorf("missing required field %q for %s\n  use: --data %s=<value>", name, svc, name)
+			}
+		}
+	}
+	return nil
+}
+
+func sortedFieldNames(schema *client.ServiceSchema) []string {
+	names := make([]string, 0, len(schema.Fields))
+	for name := range schema.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// selectorKind returns the selector type and its options, e.g. "number" and
+// {"min": 0, "max": 100}. A selector has exactly one key.
+func selectorKind(selector map[string]any) (string, map[string]any) {
+	for kind, opts := range selector {
+		m, _ := opts.(map[string]any)
+		return kind, m
+	}
+	return "", nil
+}
+
+// checkSelectorValue validates v against a field selector. Selector kinds
+// hactl does not know are accepted as-is.
+func checkSelectorValue(selector map[string]any, v any) error {
+	kind, opts := selectorKind(selector)
+	switch kind {
+	case "number":
+		f, ok := toFloat(v)
+		if !ok {
+			return fmt.Errorf("%v is not a number", v)
+		}
+		if lo, ok := toFloat(opts["min"]); ok && f < lo {
+			return fmt.Errorf("%v is below the minimum %s", v, formatNumber(lo))
+		}
+		if hi, ok := toFloat(opts["max"]); ok && f > hi {
+			return fmt.Errorf("%v is above the maximum %s", v, formatNumber(hi))
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%v is not a boolean (use true or false)", v)
+		}
+	case "select":
+		if custom, _ := opts["custom_value"].(bool); custom {
+			return nil
+		}
+		valid := selectOptions(opts)
+		values := []any{v}
+		if multiple, _ := opts["multiple"].(bool); multiple {
+			if list, ok := v.([]any); ok {
+				values = list
+			}
+		}
+		for _, item := range values {
+			if !containsString(valid, fmt.Sprint(item)) {
+				return fmt.Errorf("%v is not one of: %s", item, strings.Join(valid, ", "))
+			}
+		}
+	case "color_rgb":
+		list, ok := toList(v)
+		if !ok || len(list) != 3 {
+			return fmt.Errorf("%v is not an [R, G, B] list", v)
+		}
+		for _, c := range list {
+			f, ok := toFloat(c)
+			if !ok || f != math.Trunc(f) || f < 0 || f > 255 {
+				return fmt.Errorf("%v is not an [R, G, B] list of integers 0-255", v)
+			}
+		}
+	case "color_temp":
+		f, ok := toFloat(v)
+		if !ok {
+			return fmt.Errorf("%v is not a number", v)
+		}
+		lo, hi, unit := colorTempRange(opts)
+		if lo > 0 && f < lo || hi > 0 && f > hi {
+			return fmt.Errorf("%v is outside %s-%s %s", v, formatNumber(lo), formatNumber(hi), unit)
+		}
+	}
+	return nil
+}
+
+// colorTempRange returns the bounds and unit of a color_temp selector.
+// Older selectors give min_mireds/max_mireds; newer ones a unit with min/max.
+func colorTempRange(opts map[string]any) (lo, hi float64, unit string) {
+	unit = "mired"
+	if u, _ := opts["unit"].(string); u != "" {
+		unit = u
+	}
+	lo, _ = toFloat(opts["min"])
+	hi, _ = toFloat(opts["max"])
+	if lo == 0 && hi == 0 {
+		lo, _ = toFloat(opts["min_mireds"])
+		hi, _ = toFloat(opts["max_mireds"])
+	}
+	return lo, hi, unit
+}
+
+// selectOptions returns the allowed values of a select selector, whose
+// options are either plain strings or {value, label} objects.
+func selectOptions(opts map[string]any) []string {
+	raw, _ := opts["options"].([]any)
+	out := make([]string, 0, len(raw))
+	for _, o := range raw {
+		switch o := o.(type) {
+		case map[string]any:
+			out = append(out, fmt.Sprint(o["value"]))
+		default:
+			out = append(out, fmt.Sprint(o))
+		}
+	}
+	return out
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func toList(v any) ([]any, bool) {
+	switch l := v.(type) {
+	case []any:
+		return l, true
+	case []int:
+		out := make([]any, len(l))
+		for i, n := range l {
+			out[i] = n
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+// describeSelector renders a selector as a short type description, e.g.
+// "number 0-100 %" or "select: heat|cool|off".
+func describeSelector(selector map[string]any) string {
+	kind, opts := selectorKind(selector)
+	switch kind {
+	case "":
+		return "any"
+	case "number":
+		desc := "number"
+		lo, hasLo := toFloat(opts["min"])
+		hi, hasHi := toFloat(opts["max"])
+		if hasLo && hasHi {
+			desc += fmt.Sprintf(" %s-%s", formatNumber(lo), formatNumber(hi))
+		}
+		if unit, _ := opts["unit_of_measurement"].(string); unit != "" {
+			desc += " " + unit
+		}
+		return desc
+	case "select":
+		desc := "select: " + strings.Join(selectOptions(opts), "|")
+		if multiple, _ := opts["multiple"].(bool); multiple {
+			desc += " (multiple)"
+		}
+		return desc
+	case "color_temp":
+		lo, hi, unit := colorTempRange(opts)
+		if lo > 0 || hi > 0 {
+			return fmt.Sprintf("color_temp %s-%s %s", formatNumber(lo), formatNumber(hi), unit)
+		}
+		return "color_temp " + unit
+	case "entity":
+		if domain, ok := opts["domain"]; ok {
+			return fmt.Sprintf("entity (%v)", domain)
+		}
+	}
+	return kind
+}
+
+// formatSchemaPlain renders a service schema as human-readable lines.
+func formatSchemaPlain(schema *client.ServiceSchema) []string {
+	title := schema.Domain + "." + schema.Service
+	if schema.Name != "" {
+		title += " — " + schema.Name
+	}
+	lines := []string{title}
+	if schema.Description != "" {
+		lines = append(lines, "  "+schema.Description)
+	}
+	if schema.Target != nil {
+		lines = append(lines, "target: "+describeTarget(schema.Target))
+	}
+	if len(schema.Fields) == 0 {
+		lines = append(lines, "fields: none")
+		return lines
+	}
+	lines = append(lines, "fields:")
+	for _, name := range sortedFieldNames(schema) {
+		f := schema.Fields[name]
+		req := "optional"
+		if f.Required {
+			req = "required"
+		}
+		line := fmt.Sprintf("  %s (%s, %s)", name, describeSelector(f.Selector), req)
+		if f.Description != "" {
+			line += ": " + f.Description
+		}
+		if f.Example != nil {
+			line += fmt.Sprintf(" e.g. %v", f.Example)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// describeTarget summarises a target spec, e.g. "entity (light)".
+func describeTarget(target map[string]any) string {
+	entity, ok := target["entity"]
+	if !ok {
+		return "entity, device or area"
+	}
+	var domains []string
+	specs, _ := entity.([]any)
+	if spec, ok := entity.(map[string]any); ok {
+		specs = []any{spec}
+	}
+	for _, s := range specs {
+		spec, _ := s.(map[string]any)
+		switch d := spec["domain"].(type) {
+		case string:
+			domains = append(domains, d)
+		case []any:
+			for _, x := range d {
+				domains = append(domains, fmt.Sprint(x))
+			}
+		}
+	}
+	if len(domains) == 0 {
+		return "entity"
+	}
+	return "entity (" + strings.Join(domains, ", ") + ")"
+}
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/joaobarroca93/hactl/client"
+)
+
+func lightTurnOnSchema() *client.ServiceSchema {
+	return &client.ServiceSchema{
+		Domain:  "light",
+		Service: "turn_on",
+		Name:    "Turn on",
+		Target:  map[string]any{"entity": []any{map[string]any{"domain": []any{"light"}}}},
+		Fields: map[string]client.ServiceField{
+			"brightness_pct": {
+				Description: "Brightness in percent.",
+				Example:     47,
+				Selector:    map[string]any{"number": map[string]any{"min": 0.0, "max": 100.0, "unit_of_measurement": "%"}},
+			},
+			"flash": {
+				Selector: map[string]any{"select": map[string]any{"options": []any{"long", "short"}}},
+			},
+			"effect": {
+				Selector: map[string]any{"text": nil},
+			},
+			"rgb_color": {
+				Selector: map[string]any{"color_rgb": nil},
+			},
+			"color_temp": {
+				Selector: map[string]any{"color_temp": map[string]any{"min_mireds": 153.0, "max_mireds": 500.0}},
+			},
+			"white": {
+				Selector: map[string]any{"boolean": nil},
+			},
+		},
+	}
+}
+
+func TestValidateServiceData(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    map[string]any
+		wantErr string
+	}{
+		{"valid number", map[string]any{"brightness_pct": int64(80)}, ""},
+		{"target keys allowed", map[string]any{"entity_id": "light.kitchen", "area_id": "kitchen"}, ""},
+		{"unknown key", map[string]any{"brightnes_pct": int64(80)}, `unknown field "brightnes_pct"`},
+		{"above max", map[string]any{"brightness_pct": int64(150)}, "above the maximum 100"},
+		{"not a number", map[string]any{"brightness_pct": "bright"}, "not a number"},
+		{"select option", map[string]any{"flash": "short"}, ""},
+		{"bad select option", map[string]any{"flash": "medium"}, "not one of: long, short"},
+		{"text accepts anything", map[string]any{"effect": "rainbow"}, ""},
+		{"rgb list", map[string]any{"rgb_color": []any{255.0, 0.0, 128.0}}, ""},
+		{"rgb out of range", map[string]any{"rgb_color": []any{256.0, 0.0, 0.0}}, "integers 0-255"},
+		{"rgb string", map[string]any{"rgb_color": "255,0,0"}, "[R, G, B]"},
+		{"color temp range", map[string]any{"color_temp": int64(600)}, "outside 153-500 mired"},
+		{"boolean", map[string]any{"white": "yes"}, "not a boolean"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateServiceData(lightTurnOnSchema(), tt.data, tt.data)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got error %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateServiceDataRequired(t *testing.T) {
+	schema := &client.ServiceSchema{
+		Domain:  "climate",
+		Service: "set_hvac_mode",
+		Fields: map[string]client.ServiceField{
+			"hvac_mode": {
+				Required: true,
+				Selector: map[string]any{"select": map[string]any{"options": []any{
+					map[string]any{"value": "heat", "label": "Heat"},
+					map[string]any{"value": "off", "label": "Off"},
+				}}},
+			},
+		},
+	}
+	if err := validateServiceData(schema, nil, map[string]any{"entity_id": "climate.bedroom"}); err == nil || !strings.Contains(err.Error(), `missing required field "hvac_mode"`) {
+		t.Errorf("got %v, want missing required field", err)
+	}
+	// Supplied by a convenience flag: present in the payload only.
+	if err := validateServiceData(schema, nil, map[string]any{"hvac_mode": "heat"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	data := map[string]any{"hvac_mode": "cool"}
+	if err := validateServiceData(schema, data, data); err == nil || !strings.Contains(err.Error(), "not one of: heat, off") {
+		t.Errorf("got %v, want invalid option", err)
+	}
+}
+
+func TestValidateServiceDataOpenDomains(t *testing.T) {
+	data := map[string]any{"anything": int64(1)}
+	script := &client.ServiceSchema{Domain: "script", Service: "morning", Fields: map[string]client.ServiceField{
+		"mode": {Selector: map[string]any{"text": nil}},
+	}}
+	if err := validateServiceData(script, data, data); err != nil {
+		t.Errorf("script variables should pass: %v", err)
+	}
+	noFields := &client.ServiceSchema{Domain: "notify", Service: "legacy"}
+	if err := validateServiceData(noFields, data, data); err != nil {
+		t.Errorf("services without declared fields should pass: %v", err)
+	}
+}
+
+func TestFormatSchemaPlain(t *testing.T) {
+	got := strings.Join(formatSchemaPlain(lightTurnOnSchema()), "\n")
+	for _, want := range []string{
+		"light.turn_on — Turn on",
+		"target: entity (light)",
+		"  brightness_pct (number 0-100 %, optional): Brightness in percent. e.g. 47",
+		"  flash (select: long|short, optional)",
+		"  color_temp (color_temp 153-500 mired, optional)",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+}
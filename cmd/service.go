@@ -1,9 +1,14 @@
 package cmd
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/joaobarroca93/hactl/client"
@@ -56,10 +61,50 @@ var serviceCallCmd = &cobra.Command{
 	Short: "Call a Home Assistant service",
 	Long: `Call a Home Assistant service.
 
+Targets are given with --entity (repeatable; entity IDs, friendly names or
+globs), --area, --device and --label. Every entity is checked against the
+entity filter and must match the service's domain. In filter.mode: exposed,
+area, device and label targets are expanded to the exposed entities they
+contain; otherwise they are passed to Home Assistant as-is.
+
+The payload is built from --data-file (YAML or JSON, - for stdin),
+--data-json and --data, in that order, then the convenience flags
+(--brightness, --rgb, …), then the targets; later sources override earlier
+ones key by key. --data key=value guesses the value's type; use
+--data key:=<json> for lists, objects or strings that look like numbers.
+
+After the call, hactl waits (up to --wait, 3s by default) for every targeted
+entity to report a state change caused by the call — including attribute-only
+changes such as a new brightness — and prints the resulting states. Entities
+that report nothing in time are read back as they are. --no-wait returns as
+soon as Home Assistant accepts the call.
+
+Services that return data (weather.get_forecasts, calendar.get_events,
+scripts with a response_variable, …) need --response, which prints the
+service's response instead of the changed states.
+
+Payload keys and values are checked against the service's schema (see
+service describe) before the call is sent; --no-validate skips the check.
+
+Convenience flags by domain (see the flag list for units and ranges):
+` + convenienceHelp() + `
+
 Examples:
   hactl service call light.turn_on --entity light.living_room --brightness 80
+  hactl service call light.turn_off --entity "kitchen light"
   hactl service call climate.set_temperature --entity climate.bedroom --temperature 21.0
   hactl service call switch.toggle --entity switch.fan
+  hactl service call light.turn_off --entity light.kitchen --entity light.hallway
+  hactl service call light.turn_off --entity 'light.bedroom_*'
+  hactl service call light.turn_off --area kitchen
+  hactl service call light.turn_on --entity light.desk --kelvin 2700 --transition 2
+  hactl service call light.turn_on --entity light.desk --color '#ff8800'
+  hactl service call cover.set_cover_position --entity cover.blinds --position 40
+  hactl service call media_player.volume_set --entity media_player.tv --volume 25
+  hactl service call light.turn_on --entity light.desk --data rgb_color:='[255,0,0]'
+  hactl service call notify.notify --data-json '{"message": "Hi", "data": {"tag": "x"}}'
+  hactl service call script.morning --data-file payload.yaml
+  hactl service call weather.get_forecasts --entity weather.home --data type=daily --response
   hactl service call homeassistant.restart`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -77,89 +122,179 @@ Examples:
 			)
 		}
 
-		// Build data payload from flags
+		// The payload is built in layers, each overriding the previous one:
+		// --data-file < --data-json < --data < convenience flags < targets.
+		userData, err := readPayload(cmd)
+		if err != nil {
+			return output.Err("%s", err)
+		}
+		if entityFilter.Mode() == "exposed" {
+			for k := range userData {
+				if targetKeys[k] {
+					return output.Err("%s cannot be set in the payload in exposed mode\n  use --entity, --area, --device or --label", k)
+				}
+			}
+		}
 		data := map[string]any{}
+		for k, v := range userData {
+			data[k] = v
+		}
+		target := map[string]any{}
 
-		entity, _ := cmd.Flags().GetString("entity")
+		entityRefs, _ := cmd.Flags().GetStringArray("entity")
+		areaRefs, _ := cmd.Flags().GetStringArray("area")
+		deviceRefs, _ := cmd.Flags().GetStringArray("device")
+		labelRefs, _ := cmd.Flags().GetStringArray("label")
+		hasRegistryTargets := len(areaRefs)+len(deviceRefs)+len(labelRefs) > 0
 
 		// Fail early for domains that always require an entity.
-		if entity == "" && entityRequiredServiceDomains[domain] {
+		if len(entityRefs) == 0 && !hasRegistryTargets && entityRequiredServiceDomains[domain] {
 			return output.Err(
 				"service %s.%s requires --entity\n  use: hactl service call %s.%s --entity %s.<entity_id>",
 				domain, svc, domain, svc, domain,
 			)
 		}
-		if entity != "" {
-			if !entityFilter.IsAllowed(entity) {
-				return output.Err("entity not found: %s", entity)
+
+		// Resolve friendly names within the service's domain;
+		// homeassistant.* services accept any domain.
+		resolveDomain := domain
+		if domain == "homeassistant" {
+			resolveDomain = ""
+		}
+		entities, err := resolveServiceEntities(entityRefs, resolveDomain)
+		if err != nil {
+			return output.Err("%s", err)
+		}
+		for _, e := range entities {
+			if err := checkServiceDomain(domain, svc, e); err != nil {
+				return output.Err("%s", err)
+			}
+		}
+
+		// tracked lists every entity the call should affect, for settling.
+		tracked := entities
+
+		// Area, device and label targets. In exposed mode they are expanded
+		// to the visible entities they contain, so hidden entities in the
+		// same area are never touched; otherwise they are passed to HA as a
+		// native target and the expansion is only used to track settling.
+		if hasRegistryTargets {
+			ws, err := dialWS()
+			if err != nil {
+				return output.Err("%s", err)
 			}
-			// Warn when the entity's domain doesn't match the service's domain.
-			// homeassistant.* services (turn_on, turn_off, toggle) are cross-domain by design.
-			if domain != "homeassistant" {
-				entityDomain, _, _ := strings.Cut(entity, ".")
-				if entityDomain != domain {
-					return output.Err(
-						"domain mismatch: service %s.%s cannot target a %s entity\n  did you mean: hactl service call %s.%s --entity %s",
-						domain, svc, entityDomain, entityDomain, svc, entity,
-					)
+			idx, err := ws.FetchTargetIndex()
+			ws.Close()
+			if err != nil {
+				return output.Err("%s", err)
+			}
+			targets, err := resolveRegistryTargets(idx, areaRefs, deviceRefs, labelRefs)
+			if err != nil {
+				return output.Err("%s", err)
+			}
+			for _, id := range targetMembers(idx, targets, resolveDomain) {
+				if entityFilter.IsAllowed(id) && !containsString(tracked, id) {
+					tracked = append(tracked, id)
+				}
+			}
+			if entityFilter.Mode() == "exposed" {
+				if len(tracked) == 0 {
+					return output.Err("no exposed entities for %s.%s in the given --area/--device/--label", domain, svc)
+				}
+				entities = tracked
+			} else {
+				if len(targets.AreaIDs) > 0 {
+					target["area_id"] = targets.AreaIDs
+				}
+				if len(targets.DeviceIDs) > 0 {
+					target["device_id"] = targets.DeviceIDs
+				}
+				if len(targets.LabelIDs) > 0 {
+					target["label_id"] = targets.LabelIDs
 				}
 			}
-			data["entity_id"] = entity
 		}
 
-		// Generic --data key=value flags
-		dataFlags, _ := cmd.Flags().GetStringArray("data")
-		for _, kv := range dataFlags {
-			k, v, found := strings.Cut(kv, "=")
-			if !found {
-				return output.Err("--data must be in key=value format, got: %s", kv)
-			}
-			data[k] = parseValue(v)
+		switch len(entities) {
+		case 0:
+		case 1:
+			target["entity_id"] = entities[0]
+		default:
+			target["entity_id"] = entities
 		}
 
 		// Convenience flags
-		if brightness, err := cmd.Flags().GetInt("brightness"); err == nil && cmd.Flags().Changed("brightness") {
-			data["brightness"] = int(float64(brightness) / 100 * 255)
-		}
-		if temperature, err := cmd.Flags().GetFloat64("temperature"); err == nil && cmd.Flags().Changed("temperature") {
-			data["temperature"] = temperature
-		}
-		if colorTemp, err := cmd.Flags().GetInt("color-temp"); err == nil && cmd.Flags().Changed("color-temp") {
-			data["color_temp"] = colorTemp
+		if err := applyConvenienceFlags(cmd, domain, data); err != nil {
+			return output.Err("%s", err)
 		}
-		if hvacMode, err := cmd.Flags().GetString("hvac-mode"); err == nil && cmd.Flags().Changed("hvac-mode") {
-			data["hvac_mode"] = hvacMode
+		for k, v := range target {
+			data[k] = v
 		}
-		if rgb, err := cmd.Flags().GetString("rgb"); err == nil && cmd.Flags().Changed("rgb") {
-			rgbParts := strings.Split(rgb, ",")
-			if len(rgbParts) == 3 {
-				r, _ := strconv.Atoi(strings.TrimSpace(rgbParts[0]))
-				g, _ := strconv.Atoi(strings.TrimSpace(rgbParts[1]))
-				b, _ := strconv.Atoi(strings.TrimSpace(rgbParts[2]))
-				data["rgb_color"] = []int{r, g, b}
+
+		// Check the payload against the service's declared fields, so typos
+		// and out-of-range values fail here instead of deep inside HA. A
+		// call without payload keys has nothing to check; when the schema
+		// cannot be fetched, the call goes ahead unchecked.
+		if noValidate, _ := cmd.Flags().GetBool("no-validate"); !noValidate {
+			if len(userData) > 0 || convenienceFlagsSet(cmd) {
+				schema, err := getClient().DescribeService(domain, svc)
+				switch {
+				case errors.Is(err, client.ErrServiceNotFound):
+					return output.Err("%s", err)
+				case err != nil:
+					fmt.Fprintf(os.Stderr, "warning: skipping payload validation: %s\n", err)
+				default:
+					if err := validateServiceData(schema, userData, data); err != nil {
+						return output.Err("%s\n  run: hactl service describe %s.%s (or pass --no-validate)", err, domain, svc)
+					}
+				}
+			}
+			// Colors and color temperatures must also suit every targeted light.
+			if (domain == "light" || domain == "homeassistant") && len(tracked) > 0 {
+				states, err := filteredStates()
+				if err != nil {
+					return output.Err("%s", err)
+				}
+				var targeted []client.State
+				for _, s := range states {
+					if containsString(tracked, s.EntityID) {
+						targeted = append(targeted, s)
+					}
+				}
+				if err := checkLightCapabilities(targeted, data); err != nil {
+					return output.Err("%s\n  check the light's supported_color_modes attribute (or pass --no-validate)", err)
+				}
 			}
 		}
 
-		// Snapshot state before the call so we can detect when it settles.
-		var stateBefore *client.State
-		if entity != "" {
-			stateBefore, _ = getClient().GetState(entity)
+		// Services that return data are queries; print their response
+		// instead of waiting for state changes.
+		if wantResponse, _ := cmd.Flags().GetBool("response"); wantResponse {
+			result, err := getClient().CallServiceWithResponse(domain, svc, data)
+			if err != nil {
+				return output.Err("%s", err)
+			}
+			if quiet {
+				return nil
+			}
+			var response any
+			if err := json.Unmarshal(result.Response, &response); err != nil {
+				return output.Err("failed to parse service response: %s", err)
+			}
+			if plain {
+				for _, line := range flattenPlain("", response) {
+					output.PrintPlain(line)
+				}
+				return nil
+			}
+			return output.PrintJSON(response)
 		}
 
-		_, err := getClient().CallService(domain, svc, data)
+		noWait, _ := cmd.Flags().GetBool("no-wait")
+		wait, _ := cmd.Flags().GetDuration("wait")
+		states, err := callTracked(domain, svc, data, tracked, noWait, wait)
 		if err != nil {
-			return output.Err("%s", err)
-		}
-
-		// Poll until the entity state changes or the timeout elapses.
-		// Many integrations return an empty/stale response immediately after
-		// a service call — the device hasn't acted yet.
-		var states []client.State
-		if entity != "" {
-			s := pollStateChange(entity, stateBefore, 3*time.Second, 250*time.Millisecond)
-			if s != nil {
-				states = []client.State{*s}
-			}
+			return serviceCallErr(err)
 		}
 
 		if quiet {
@@ -210,38 +345,145 @@ Examples:
 	},
 }
 
+var serviceDescribeCmd = &cobra.Command{
+	Use:   "describe <domain.service>",
+	Short: "Show the fields a service accepts",
+	Long: `Show a service's fields with their types, ranges and examples, and
+which entities it can target.
+
+Examples:
+  hactl service describe light.turn_on
+  hactl service describe climate.set_hvac_mode --plain`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		domain, svc, found := strings.Cut(args[0], ".")
+		if !found || domain == "" || svc == "" {
+			return output.Err("service must be in format domain.service (e.g. light.turn_on)")
+		}
+		schema, err := getClient().DescribeService(domain, svc)
+		if err != nil {
+			return output.Err("%s", err)
+		}
+		if quiet {
+			return nil
+		}
+		if plain {
+			for _, line := range formatSchemaPlain(schema) {
+				output.PrintPlain(line)
+			}
+			return nil
+		}
+		return output.PrintJSON(schema)
+	},
+}
+
 func init() {
-	serviceCallCmd.Flags().String("entity", "", "entity_id to target")
-	serviceCallCmd.Flags().StringArray("data", nil, "additional key=value pairs (repeatable)")
-	serviceCallCmd.Flags().Int("brightness", 0, "brightness percentage (0-100), for light services")
-	serviceCallCmd.Flags().Float64("temperature", 0, "target temperature, for climate services")
-	serviceCallCmd.Flags().Int("color-temp", 0, "color temperature in mireds, for light services")
-	serviceCallCmd.Flags().String("hvac-mode", "", "HVAC mode (heat, cool, auto, off), for climate services")
-	serviceCallCmd.Flags().String("rgb", "", "RGB color as R,G,B (e.g. 255,128,0)")
+	serviceCallCmd.Flags().StringArray("entity", nil, "entity to target: entity ID, friendly name or glob (repeatable)")
+	serviceCallCmd.Flags().StringArray("area", nil, "target every entity in an area, by ID or name (repeatable)")
+	serviceCallCmd.Flags().StringArray("device", nil, "target every entity of a device, by ID or name (repeatable)")
+	serviceCallCmd.Flags().StringArray("label", nil, "target every entity with a label, by ID or name (repeatable)")
+	addPayloadFlags(serviceCallCmd)
+	addConvenienceFlags(serviceCallCmd)
+	serviceCallCmd.Flags().Bool("response", false, "request and print the data the service returns (return_response)")
+	serviceCallCmd.Flags().Duration("wait", defaultSettleWait, "how long to wait for targeted entities to report the change")
+	serviceCallCmd.Flags().Bool("no-wait", false, "return as soon as Home Assistant accepts the call")
+	serviceCallCmd.Flags().Bool("no-validate", false, "send --data without checking it against the service schema")
 
 	serviceListCmd.Flags().String("domain", "", "filter by domain (e.g. notify, light)")
 
 	serviceCmd.AddCommand(serviceCallCmd)
 	serviceCmd.AddCommand(serviceListCmd)
+	serviceCmd.AddCommand(serviceDescribeCmd)
 }
 
-// pollStateChange polls entityID until its state differs from before, or timeout elapses.
-// Returns the latest state in either case (nil only if all fetches fail).
-func pollStateChange(entityID string, before *client.State, timeout, interval time.Duration) *client.State {
-	deadline := time.Now().Add(timeout)
-	var last *client.State
-	for time.Now().Before(deadline) {
-		time.Sleep(interval)
-		s, err := getClient().GetState(entityID)
+// callTracked calls domain.svc and returns the states of the tracked
+// entities. Unless noWait is set or wait is not positive, it waits for them
+// to report the change, so the result shows the settled state rather than a
+// stale snapshot.
+func callTracked(domain, svc string, data map[string]any, tracked []string, noWait bool, wait time.Duration) ([]client.State, error) {
+	if noWait || wait <= 0 || len(tracked) == 0 {
+		changed, err := getClient().CallService(domain, svc, data)
 		if err != nil {
-			continue
+			return nil, err
 		}
-		last = s
-		if before == nil || s.State != before.State {
-			return s
+		// Report only what was targeted: the changed states may include
+		// entities hidden by the filter.
+		var states []client.State
+		for _, s := range changed {
+			if containsString(tracked, s.EntityID) {
+				states = append(states, s)
+			}
 		}
+		return states, nil
+	}
+	ws, err := dialWS()
+	if err != nil {
+		return nil, err
+	}
+	sess := ws.StartSession()
+	defer sess.Close()
+	return callAndSettle(sess, domain, svc, data, tracked, wait)
+}
+
+// serviceCallErr reports a failed call, pointing at --response when the
+// service only works with it.
+func serviceCallErr(err error) error {
+	if strings.Contains(err.Error(), "requires responses") {
+		return output.Err("%s\n  this service returns data: add --response", err)
+	}
+	return output.Err("%s", err)
+}
+
+// flattenPlain renders v as "path: value" lines, one per scalar, with
+// object keys sorted, e.g. "weather.home.forecast[0].temperature: 21".
+func flattenPlain(prefix string, v any) []string {
+	switch v := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var lines []string
+		for _, k := range keys {
+			path := k
+			if prefix != "" {
+				path = prefix + "." + k
+			}
+			lines = append(lines, flattenPlain(path, v[k])...)
+		}
+		return lines
+	case []any:
+		var lines []string
+		for i, item := range v {
+			lines = append(lines, flattenPlain(fmt.Sprintf("%s[%d]", prefix, i), item)...)
+		}
+		return lines
+	}
+	if prefix == "" {
+		return []string{fmt.Sprint(v)}
+	}
+	return []string{fmt.Sprintf("%s: %v", prefix, v)}
+}
+
+// fetchStates returns the current state of each entity, fetched concurrently.
+// Entities whose state cannot be read map to nil.
+func fetchStates(entityIDs []string) map[string]*client.State {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	states := make(map[string]*client.State, len(entityIDs))
+	for _, id := range entityIDs {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			s, _ := getClient().GetState(id)
+			mu.Lock()
+			states[id] = s
+			mu.Unlock()
+		}(id)
 	}
-	return last
+	wg.Wait()
+	return states
 }
 
 // parseValue attempts to parse a string as a number, bool, or falls back to string.
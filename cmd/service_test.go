@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -57,3 +58,26 @@ func TestRestrictedServicesDoNotBlockNormal(t *testing.T) {
 		}
 	}
 }
+
+// --- flattenPlain ---
+
+func TestFlattenPlain(t *testing.T) {
+	response := map[string]any{
+		"weather.home": map[string]any{
+			"forecast": []any{
+				map[string]any{"condition": "sunny", "temperature": 21.5},
+			},
+		},
+		"count": 2.0,
+	}
+	got := strings.Join(flattenPlain("", response), "\n")
+	want := "count: 2\n" +
+		"weather.home.forecast[0].condition: sunny\n" +
+		"weather.home.forecast[0].temperature: 21.5"
+	if got != want {
+		t.Errorf("flattenPlain() =\n%s\nwant\n%s", got, want)
+	}
+	if got := flattenPlain("", "ok"); len(got) != 1 || got[0] != "ok" {
+		t.Errorf("flattenPlain(scalar) = %v", got)
+	}
+}
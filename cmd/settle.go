@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/joaobarroca93/hactl/client"
+)
+
+// defaultSettleWait is how long service call waits for targeted entities to
+// report the change by default.
+const defaultSettleWait = 3 * time.Second
+
+// callAndSettle calls domain.svc over sess and waits until every entity in
+// tracked has reported a state change caused by the call, or until wait
+// elapses. It returns the resulting states in tracked order.
+//
+// The state_changed subscription is opened before the call so no change can
+// be missed, and changes are attributed to the call by context id, so
+// unrelated updates (a sensor ticking, another automation) are ignored while
+// attribute-only changes such as a new brightness are detected. Entities
+// that report nothing in time — already in the requested state, or slow to
+// respond — are read back at the end.
+func callAndSettle(sess *client.Session, domain, svc string, data map[string]any, tracked []string, wait time.Duration) ([]client.State, error) {
+	sub, err := sess.SubscribeEvents("state_changed")
+	if err != nil {
+		return nil, err
+	}
+	defer sub.Unsubscribe()
+
+	// Call in the background and keep draining events meanwhile: the
+	// session delivers results and events in order, so a full event buffer
+	// would otherwise hold back the call's own result.
+	type callResult struct {
+		contextID string
+		err       error
+	}
+	called := make(chan callResult, 1)
+	go func() {
+		id, err := sess.CallService(domain, svc, data)
+		called <- callResult{id, err}
+	}()
+
+	want := make(map[string]bool, len(tracked))
+	for _, id := range tracked {
+		want[id] = true
+	}
+	settled := map[string]*client.State{}
+	var early []*client.Event // events received before the context id is known
+	contextID := ""
+	callDone := false
+
+	settle := func(ev *client.Event) {
+		sc, err := ev.StateChange()
+		if err != nil || !want[sc.EntityID] || sc.NewState == nil {
+			return
+		}
+		if causedBy(ev.Context, contextID) || causedBy(sc.NewState.Context, contextID) {
+			settled[sc.EntityID] = sc.NewState
+		}
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	deadline := timer.C
+	timedOut := false
+
+	// Never give up on the call itself, only on settling: after the
+	// deadline, keep draining events until the call returns.
+	for !callDone || !timedOut && len(settled) < len(tracked) {
+		select {
+		case res := <-called:
+			if res.err != nil {
+				return nil, res.err
+			}
+			callDone, contextID = true, res.contextID
+			for _, ev := range early {
+				settle(ev)
+			}
+			early = nil
+		case raw, ok := <-sub.C:
+			if !ok {
+				return nil, fmt.Errorf("websocket closed: %v", sess.Err())
+			}
+			ev, err := client.ParseEvent(raw)
+			if err != nil {
+				continue
+			}
+			if !callDone {
+				early = append(early, ev)
+				continue
+			}
+			settle(ev)
+		case <-deadline:
+			deadline, timedOut = nil, true
+		}
+	}
+
+	var missing []string
+	for _, id := range tracked {
+		if settled[id] == nil {
+			missing = append(missing, id)
+		}
+	}
+	for id, s := range fetchStates(missing) {
+		settled[id] = s
+	}
+
+	var states []client.State
+	for _, id := range tracked {
+		if s := settled[id]; s != nil {
+			states = append(states, *s)
+		}
+	}
+	return states, nil
+}
+
+// causedBy reports whether ctx is the context contextID or a child of it.
+func causedBy(ctx map[string]any, contextID string) bool {
+	if contextID == "" || ctx == nil {
+		return false
+	}
+	return ctx["id"] == contextID || ctx["parent_id"] == contextID
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joaobarroca93/hactl/client"
+)
+
+func TestCausedBy(t *testing.T) {
+	tests := []struct {
+		ctx  map[string]any
+		id   string
+		want bool
+	}{
+		{map[string]any{"id": "abc"}, "abc", true},
+		{map[string]any{"id": "def", "parent_id": "abc"}, "abc", true},
+		{map[string]any{"id": "def", "parent_id": nil}, "abc", false},
+		{nil, "abc", false},
+		{map[string]any{"id": ""}, "", false},
+	}
+	for _, tt := range tests {
+		if got := causedBy(tt.ctx, tt.id); got != tt.want {
+			t.Errorf("causedBy(%v, %q) = %v, want %v", tt.ctx, tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestCallAndSettle(t *testing.T) {
+	f := newFakeHA(t)
+	f.setState(client.State{EntityID: "light.b", State: "off"})
+	f.setState(client.State{EntityID: "light.c", State: "off"})
+	f.onCall = func(c *fakeHAConn, id int, msg map[string]any) {
+		// An event can arrive before the call's own result.
+		c.fire(contextStateEvent("light.a", "on", "ctx-1", ""))
+		c.result(id, map[string]any{"context": map[string]any{"id": "ctx-1"}})
+		c.fire(contextStateEvent("light.b", "on", "ctx-other", ""))  // another caller's change
+		c.fire(contextStateEvent("light.d", "on", "ctx-1", ""))      // not tracked
+		c.fire(contextStateEvent("light.c", "on", "ctx-2", "ctx-1")) // caused by an automation the call triggered
+	}
+
+	ws, err := dialWS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess := ws.StartSession()
+	defer sess.Close()
+
+	start := time.Now()
+	states, err := callAndSettle(sess, "light", "turn_on", map[string]any{"entity_id": []string{"light.a", "light.b", "light.c"}}, []string{"light.a", "light.b", "light.c"}, 300*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Errorf("returned after %s: light.b never settles, so the wait should run out", elapsed)
+	}
+	got := map[string]string{}
+	for _, s := range states {
+		got[s.EntityID] = s.State
+	}
+	want := map[string]string{"light.a": "on", "light.b": "off", "light.c": "on"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("states = %v, want %v (light.b read back, its foreign change ignored)", got, want)
+	}
+}
+
+func TestCallAndSettleReturnsOnceSettled(t *testing.T) {
+	f := newFakeHA(t)
+	f.onCall = func(c *fakeHAConn, id int, msg map[string]any) {
+		c.result(id, map[string]any{"context": map[string]any{"id": "ctx-1"}})
+		c.fire(contextStateEvent("light.a", "on", "ctx-1", ""))
+	}
+	ws, err := dialWS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess := ws.StartSession()
+	defer sess.Close()
+
+	start := time.Now()
+	states, err := callAndSettle(sess, "light", "turn_on", nil, []string{"light.a"}, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("took %s, want a return as soon as light.a settled", elapsed)
+	}
+	if len(states) != 1 || states[0].State != "on" {
+		t.Errorf("states = %v", states)
+	}
+}
+
+func TestCallAndSettleCallError(t *testing.T) {
+	f := newFakeHA(t)
+	f.onCall = func(c *fakeHAConn, id int, msg map[string]any) {
+		_ = c.send(map[string]any{"id": id, "type": "result", "success": false,
+			"error": map[string]any{"code": "invalid_format", "message": "bad brightness"}})
+	}
+	ws, err := dialWS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess := ws.StartSession()
+	defer sess.Close()
+
+	_, err = callAndSettle(sess, "light", "turn_on", nil, []string{"light.a"}, time.Second)
+	if err == nil || !strings.Contains(err.Error(), "bad brightness") {
+		t.Errorf("err = %v, want the service error", err)
+	}
+}
+
+func TestCallTrackedNoWait(t *testing.T) {
+	f := newFakeHA(t)
+	f.changed = []client.State{
+		{EntityID: "light.a", State: "on"},
+		{EntityID: "light.hidden", State: "on"},
+	}
+	states, err := callTracked("light", "turn_on", nil, []string{"light.a"}, true, 3*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 1 || states[0].EntityID != "light.a" {
+		t.Errorf("states = %v, want only the tracked light.a", states)
+	}
+	if len(f.calls) != 1 || f.calls[0] != "light.turn_on" {
+		t.Errorf("REST calls = %v, want light.turn_on", f.calls)
+	}
+	if len(f.conns) != 0 {
+		t.Error("--no-wait should not open a WebSocket connection")
+	}
+}
@@ -0,0 +1,381 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/joaobarroca93/hactl/client"
+	"github.com/joaobarroca93/hactl/output"
+	"github.com/spf13/cobra"
+)
+
+// Snapshot is a named, saved copy of the (filtered) entity states.
+type Snapshot struct {
+	Name    string         `json:"name"`
+	TakenAt time.Time      `json:"taken_at"`
+	States  []client.State `json:"states"`
+}
+
+var snapshotNameRe = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+var stateSnapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Save and manage local state snapshots",
+}
+
+var stateSnapshotSaveCmd = &cobra.Command{
+	Use:   "save <name>",
+	Short: "Save the current entity states under a name",
+	Long: `Save the current states of all visible entities to a local snapshot.
+Snapshots are stored in ~/.config/hactl/snapshots/ and compared with state diff.
+
+Examples:
+  hactl state snapshot save before_leaving
+  hactl state diff before_leaving`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		if err := validateSnapshotName(name); err != nil {
+			return output.Err("%s", err)
+		}
+		states, err := getClient().ListStates()
+		if err != nil {
+			return output.Err("%s", err)
+		}
+		snap := &Snapshot{
+			Name:    name,
+			TakenAt: time.Now().UTC(),
+			States:  entityFilter.FilterStates(states),
+		}
+		path, err := saveSnapshot(snap)
+		if err != nil {
+			return output.Err("%s", err)
+		}
+		if quiet {
+			return nil
+		}
+		if plain {
+			output.PrintPlain(fmt.Sprintf("saved %d entities to snapshot %s", len(snap.States), name))
+			return nil
+		}
+		return output.PrintJSON(map[string]any{"saved": name, "entities": len(snap.States), "path": path})
+	},
+}
+
+var stateSnapshotListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List saved snapshots",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := snapshotDir()
+		if err != nil {
+			return output.Err("cannot determine home directory: %s", err)
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil && !os.IsNotExist(err) {
+			return output.Err("read snapshots: %s", err)
+		}
+
+		type snapshotInfo struct {
+			Name     string    `json:"name"`
+			TakenAt  time.Time `json:"taken_at"`
+			Entities int       `json:"entities"`
+		}
+		infos := []snapshotInfo{}
+		for _, e := range entries {
+			name, ok := strings.CutSuffix(e.Name(), ".json")
+			if !ok || e.IsDir() {
+				continue
+			}
+			snap, err := loadSnapshot(name)
+			if err != nil {
+				continue
+			}
+			infos = append(infos, snapshotInfo{Name: snap.Name, TakenAt: snap.TakenAt, Entities: len(snap.States)})
+		}
+		sort.Slice(infos, func(i, j int) bool { return infos[i].TakenAt.Before(infos[j].TakenAt) })
+
+		if quiet {
+			return nil
+		}
+		if plain {
+			for _, i := range infos {
+				fmt.Printf("%s: %d entities at %s\n", i.Name, i.Entities, i.TakenAt.Local().Format("2006-01-02 15:04"))
+			}
+			return nil
+		}
+		return output.PrintJSON(infos)
+	},
+}
+
+var stateSnapshotDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Delete a saved snapshot",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		path, err := snapshotPath(name)
+		if err != nil {
+			return output.Err("%s", err)
+		}
+		if err := os.Remove(path); err != nil {
+			if os.IsNotExist(err) {
+				return output.Err("snapshot not found: %s", name)
+			}
+			return output.Err("delete snapshot: %s", err)
+		}
+		if quiet {
+			return nil
+		}
+		if plain {
+			output.PrintPlain(fmt.Sprintf("deleted snapshot %s", name))
+			return nil
+		}
+		return output.PrintJSON(map[string]string{"deleted": name})
+	},
+}
+
+var stateDiffCmd = &cobra.Command{
+	Use:   "diff <snapshot> [other|now]",
+	Short: "Show what changed between a snapshot and now (or another snapshot)",
+	Long: `Compare a saved snapshot with the current states, or with another snapshot.
+Reports entities whose state changed, and entities that appeared or vanished.
+Attributes are compared only when selected with --attr.
+
+Examples:
+  hactl state diff before_leaving
+  hactl state diff before_leaving now --plain
+  hactl state diff morning evening --attr brightness --attr temperature`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		attrs, _ := cmd.Flags().GetStringArray("attr")
+
+		from, err := loadSnapshot(args[0])
+		if err != nil {
+			return output.Err("%s", err)
+		}
+		to := &Snapshot{Name: "now", TakenAt: time.Now().UTC()}
+		if len(args) == 2 && args[1] != "now" {
+			to, err = loadSnapshot(args[1])
+			if err != nil {
+				return output.Err("%s", err)
+			}
+		} else {
+			to.States, err = getClient().ListStates()
+			if err != nil {
+				return output.Err("%s", err)
+			}
+		}
+
+		// Apply the current filter to both sides: a snapshot saved under
+		// filter.mode: all must not reveal hidden entities later.
+		d := diffStates(entityFilter.FilterStates(from.States), entityFilter.FilterStates(to.States), attrs)
+		d.From, d.To = from.Name, to.Name
+
+		if quiet {
+			return nil
+		}
+		if plain {
+			output.PrintPlain(formatDiffPlain(d))
+			return nil
+		}
+		return output.PrintJSON(d)
+	},
+}
+
+func init() {
+	stateDiffCmd.Flags().StringArray("attr", nil, "attribute to compare in addition to state (repeatable)")
+
+	stateSnapshotCmd.AddCommand(stateSnapshotSaveCmd)
+	stateSnapshotCmd.AddCommand(stateSnapshotListCmd)
+	stateSnapshotCmd.AddCommand(stateSnapshotDeleteCmd)
+	stateCmd.AddCommand(stateSnapshotCmd)
+	stateCmd.AddCommand(stateDiffCmd)
+}
+
+// snapshotDir returns the directory holding saved snapshots.
+func snapshotDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "hactl", "snapshots"), nil
+}
+
+func validateSnapshotName(name string) error {
+	if name == "now" || !snapshotNameRe.MatchString(name) {
+		return fmt.Errorf("invalid snapshot name %q: use letters, digits, '.', '_' or '-' (and not \"now\")", name)
+	}
+	return nil
+}
+
+func snapshotPath(name string) (string, error) {
+	if err := validateSnapshotName(name); err != nil {
+		return "", err
+	}
+	dir, err := snapshotDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot determine home directory: %w", err)
+	}
+	return filepath.Join(dir, name+".json"), nil
+}
+
+func saveSnapshot(snap *Snapshot) (string, error) {
+	path, err := snapshotPath(snap.Name)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", fmt.Errorf("create snapshot directory: %w", err)
+	}
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return "", fmt.Errorf("marshal snapshot: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return "", fmt.Errorf("write snapshot: %w", err)
+	}
+	return path, nil
+}
+
+func loadSnapshot(name string) (*Snapshot, error) {
+	path, err := snapshotPath(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("snapshot not found: %s", name)
+		}
+		return nil, fmt.Errorf("read snapshot: %w", err)
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("parse snapshot %s: %w", name, err)
+	}
+	return &snap, nil
+}
+
+// StateDiff is the result of comparing two sets of states.
+type StateDiff struct {
+	From     string         `json:"from"`
+	To       string         `json:"to"`
+	Changed  []EntityChange `json:"changed"`
+	Appeared []EntityValue  `json:"appeared"`
+	Vanished []EntityValue  `json:"vanished"`
+}
+
+// EntityChange describes one entity whose state or selected attributes differ.
+type EntityChange struct {
+	EntityID   string                `json:"entity_id"`
+	OldState   string                `json:"old_state"`
+	NewState   string                `json:"new_state"`
+	Attributes map[string]AttrChange `json:"attributes,omitempty"`
+}
+
+// AttrChange holds the old and new values of a changed attribute.
+type AttrChange struct {
+	Old any `json:"old"`
+	New any `json:"new"`
+}
+
+// EntityValue is an entity ID with its state, used for appeared/vanished entities.
+type EntityValue struct {
+	EntityID string `json:"entity_id"`
+	State    string `json:"state"`
+}
+
+// diffStates compares two sets of states by entity ID. Only the state and
+// the attributes named in attrs are compared. Results are sorted by entity ID.
+func diffStates(from, to []client.State, attrs []string) *StateDiff {
+	d := &StateDiff{Changed: []EntityChange{}, Appeared: []EntityValue{}, Vanished: []EntityValue{}}
+
+	before := make(map[string]client.State, len(from))
+	for _, s := range from {
+		before[s.EntityID] = s
+	}
+	seen := make(map[string]bool, len(to))
+	for _, n := range to {
+		seen[n.EntityID] = true
+		o, ok := before[n.EntityID]
+		if !ok {
+			d.Appeared = append(d.Appeared, EntityValue{EntityID: n.EntityID, State: n.State})
+			continue
+		}
+		change := EntityChange{EntityID: n.EntityID, OldState: o.State, NewState: n.State}
+		for _, a := range attrs {
+			ov, nv := o.Attributes[a], n.Attributes[a]
+			if !reflect.DeepEqual(ov, nv) {
+				if change.Attributes == nil {
+					change.Attributes = map[string]AttrChange{}
+				}
+				change.Attributes[a] = AttrChange{Old: ov, New: nv}
+			}
+		}
+		if o.State != n.State || change.Attributes != nil {
+			d.Changed = append(d.Changed, change)
+		}
+	}
+	for _, o := range from {
+		if !seen[o.EntityID] {
+			d.Vanished = append(d.Vanished, EntityValue{EntityID: o.EntityID, State: o.State})
+		}
+	}
+
+	sort.Slice(d.Changed, func(i, j int) bool { return d.Changed[i].EntityID < d.Changed[j].EntityID })
+	sort.Slice(d.Appeared, func(i, j int) bool { return d.Appeared[i].EntityID < d.Appeared[j].EntityID })
+	sort.Slice(d.Vanished, func(i, j int) bool { return d.Vanished[i].EntityID < d.Vanished[j].EntityID })
+	return d
+}
+
+// formatDiffPlain renders a diff as one line per change.
+// Example: "light.kitchen: off -> on", "+ sensor.new: 12", "- sensor.old"
+func formatDiffPlain(d *StateDiff) string {
+	var lines []string
+	for _, c := range d.Changed {
+		if c.OldState != c.NewState {
+			lines = append(lines, fmt.Sprintf("%s: %s -> %s", c.EntityID, c.OldState, c.NewState))
+		}
+		keys := make([]string, 0, len(c.Attributes))
+		for k := range c.Attributes {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			a := c.Attributes[k]
+			lines = append(lines, fmt.Sprintf("%s: %s %s -> %s", c.EntityID, k, formatAttrValue(a.Old), formatAttrValue(a.New)))
+		}
+	}
+	for _, a := range d.Appeared {
+		lines = append(lines, fmt.Sprintf("+ %s: %s", a.EntityID, a.State))
+	}
+	for _, v := range d.Vanished {
+		lines = append(lines, fmt.Sprintf("- %s", v.EntityID))
+	}
+	if len(lines) == 0 {
+		return fmt.Sprintf("no changes between %s and %s", d.From, d.To)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func formatAttrValue(v any) string {
+	if v == nil {
+		return "none"
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joaobarroca93/hactl/client"
+)
+
+// --- diffStates ---
+
+func TestDiffStates(t *testing.T) {
+	old := []client.State{
+		{EntityID: "light.kitchen", State: "off", Attributes: map[string]any{"brightness": nil}},
+		{EntityID: "light.hall", State: "on", Attributes: map[string]any{"brightness": float64(120)}},
+		{EntityID: "sensor.gone", State: "12"},
+		{EntityID: "switch.same", State: "on"},
+	}
+	now := []client.State{
+		{EntityID: "light.kitchen", State: "on", Attributes: map[string]any{"brightness": float64(255)}},
+		{EntityID: "light.hall", State: "on", Attributes: map[string]any{"brightness": float64(200)}},
+		{EntityID: "sensor.new", State: "3"},
+		{EntityID: "switch.same", State: "on"},
+	}
+
+	d := diffStates(old, now, nil)
+	if len(d.Changed) != 1 || d.Changed[0].EntityID != "light.kitchen" {
+		t.Errorf("state-only diff: changed = %+v, want only light.kitchen", d.Changed)
+	}
+	if len(d.Appeared) != 1 || d.Appeared[0].EntityID != "sensor.new" {
+		t.Errorf("appeared = %+v", d.Appeared)
+	}
+	if len(d.Vanished) != 1 || d.Vanished[0].EntityID != "sensor.gone" {
+		t.Errorf("vanished = %+v", d.Vanished)
+	}
+
+	d = diffStates(old, now, []string{"brightness"})
+	if len(d.Changed) != 2 {
+		t.Fatalf("attribute diff: changed = %+v, want 2 entities", d.Changed)
+	}
+	hall := d.Changed[0]
+	if hall.EntityID != "light.hall" || hall.Attributes["brightness"].New != float64(200) {
+		t.Errorf("light.hall change = %+v", hall)
+	}
+}
+
+// --- formatDiffPlain ---
+
+func TestFormatDiffPlain(t *testing.T) {
+	d := &StateDiff{
+		From: "morning", To: "now",
+		Changed: []EntityChange{{
+			EntityID: "light.hall", OldState: "on", NewState: "on",
+			Attributes: map[string]AttrChange{"brightness": {Old: float64(120), New: float64(200)}},
+		}},
+		Appeared: []EntityValue{{EntityID: "sensor.new", State: "3"}},
+		Vanished: []EntityValue{{EntityID: "sensor.gone", State: "12"}},
+	}
+	want := "light.hall: brightness 120 -> 200\n+ sensor.new: 3\n- sensor.gone"
+	if got := formatDiffPlain(d); got != want {
+		t.Errorf("formatDiffPlain() = %q, want %q", got, want)
+	}
+
+	empty := &StateDiff{From: "a", To: "b"}
+	if got := formatDiffPlain(empty); !strings.Contains(got, "no changes") {
+		t.Errorf("empty diff = %q", got)
+	}
+}
+
+// --- snapshot store ---
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	snap := &Snapshot{
+		Name:    "before_leaving",
+		TakenAt: time.Now().UTC().Truncate(time.Second),
+		States:  []client.State{{EntityID: "light.kitchen", State: "on"}},
+	}
+	if _, err := saveSnapshot(snap); err != nil {
+		t.Fatal(err)
+	}
+	got, err := loadSnapshot("before_leaving")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.TakenAt.Equal(snap.TakenAt) || len(got.States) != 1 || got.States[0].State != "on" {
+		t.Errorf("loaded snapshot = %+v", got)
+	}
+	if _, err := loadSnapshot("missing"); err == nil {
+		t.Error("expected error for a missing snapshot")
+	}
+}
+
+func TestValidateSnapshotName(t *testing.T) {
+	for _, ok := range []string{"morning", "before-leaving", "v1.2", "a_b"} {
+		if err := validateSnapshotName(ok); err != nil {
+			t.Errorf("validateSnapshotName(%q): %v", ok, err)
+		}
+	}
+	for _, bad := range []string{"", "now", "../etc", "a b", "x/y"} {
+		if err := validateSnapshotName(bad); err == nil {
+			t.Errorf("validateSnapshotName(%q): expected error", bad)
+		}
+	}
+}
@@ -2,8 +2,15 @@ package cmd
 
 import (
 	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/joaobarroca93/hactl/client"
+	"github.com/joaobarroca93/hactl/expr"
 	"github.com/joaobarroca93/hactl/output"
 	"github.com/spf13/cobra"
 )
@@ -14,13 +21,20 @@ var stateCmd = &cobra.Command{
 }
 
 var stateGetCmd = &cobra.Command{
-	Use:   "get <entity_id>",
+	Use:   "get <entity>",
 	Short: "Get the current state of an entity",
-	Args:  cobra.ExactArgs(1),
+	Long: `Get the current state of an entity.
+
+The entity may be given by ID or by friendly name ("kitchen light").
+
+Examples:
+  hactl state get light.living_room
+  hactl state get "kitchen light" --plain`,
+	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		entityID := args[0]
-		if !entityFilter.IsAllowed(entityID) {
-			return output.Err("entity not found: %s", entityID)
+		entityID, err := resolveEntity(args[0], "")
+		if err != nil {
+			return output.Err("%s", err)
 		}
 		s, err := getClient().GetState(entityID)
 		if err != nil {
@@ -60,12 +74,35 @@ var serviceControlled = map[string]string{
 	"siren":               "siren.turn_on / siren.turn_off",
 }
 
+var stateSetRaw bool
+
 var stateSetCmd = &cobra.Command{
-	Use:   "set <entity_id> <state>",
-	Short: "Set the state of an entity",
-	Args:  cobra.ExactArgs(2),
+	Use:   "set <entity> <value>",
+	Short: "Set the value of a helper entity",
+	Long: `Set the value of a helper entity by calling its service, so the change
+persists across restarts and fires the helper's automations.
+
+  input_boolean            on/off → input_boolean.turn_on / turn_off
+  input_number, number     set_value, checked against min/max/step
+  input_text, text         set_value, checked against min/max length and pattern
+  input_select, select     select_option, checked against the options
+  input_datetime           set_datetime (date, time or "date time")
+
+--raw instead POSTs to /api/states/<entity_id>, which only overwrites Home
+Assistant's in-memory state until the integration next writes it.
+
+Examples:
+  hactl state set input_boolean.guest_mode on
+  hactl state set input_number.target_temp 21.5
+  hactl state set input_select.house_mode Away
+  hactl state set input_datetime.alarm 07:30
+  hactl state set sensor.virtual_reading 42 --raw`,
+	Args: cobra.ExactArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		entityID := args[0]
+		entityID, err := resolveEntity(args[0], "")
+		if err != nil {
+			return output.Err("%s", err)
+		}
 		newState := args[1]
 
 		domain, _, _ := strings.Cut(entityID, ".")
@@ -76,7 +113,18 @@ var stateSetCmd = &cobra.Command{
 			)
 		}
 
-		s, err := getClient().SetState(entityID, newState, nil)
+		var s *client.State
+		if stateSetRaw {
+			s, err = getClient().SetState(entityID, newState, nil)
+		} else {
+			if _, ok := helperDomains[domain]; !ok {
+				return output.Err(
+					"state set only supports helper entities (%s)\n  use --raw to overwrite the state of %s directly",
+					strings.Join(helperDomainNames(), ", "), entityID,
+				)
+			}
+			s, err = setHelperValue(entityID, newState)
+		}
 		if err != nil {
 			return output.Err("%s", err)
 		}
@@ -91,15 +139,197 @@ var stateSetCmd = &cobra.Command{
 	},
 }
 
+// helperDomains lists the domains state set drives through services.
+var helperDomains = map[string]bool{
+	"input_boolean":  true,
+	"input_number":   true,
+	"number":         true,
+	"input_text":     true,
+	"text":           true,
+	"input_select":   true,
+	"select":         true,
+	"input_datetime": true,
+}
+
+func helperDomainNames() []string {
+	names := make([]string, 0, len(helperDomains))
+	for d := range helperDomains {
+		names = append(names, d)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// setHelperValue validates value against the helper's attributes, calls the
+// matching service, and returns the resulting state.
+func setHelperValue(entityID, value string) (*client.State, error) {
+	current, err := getClient().GetState(entityID)
+	if err != nil {
+		return nil, err
+	}
+	service, data, err := helperServiceCall(current, value)
+	if err != nil {
+		return nil, err
+	}
+	domain, _, _ := strings.Cut(entityID, ".")
+	data["entity_id"] = entityID
+	if _, err := getClient().CallService(domain, service, data); err != nil {
+		return nil, err
+	}
+	return getClient().GetState(entityID)
+}
+
+// helperServiceCall returns the service and data that set a helper entity to
+// value, validating value against the entity's attributes.
+func helperServiceCall(s *client.State, value string) (string, map[string]any, error) {
+	domain, _, _ := strings.Cut(s.EntityID, ".")
+	attrs := s.Attributes
+
+	switch domain {
+	case "input_boolean":
+		switch strings.ToLower(value) {
+		case "on", "true", "1":
+			return "turn_on", map[string]any{}, nil
+		case "off", "false", "0":
+			return "turn_off", map[string]any{}, nil
+		}
+		return "", nil, fmt.Errorf("invalid value %q for %s: use on or off", value, s.EntityID)
+
+	case "input_number", "number":
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid value %q for %s: must be a number", value, s.EntityID)
+		}
+		minV, hasMin := toFloat(attrs["min"])
+		maxV, hasMax := toFloat(attrs["max"])
+		if hasMin && v < minV || hasMax && v > maxV {
+			bounds := "between " + formatNumber(minV) + " and " + formatNumber(maxV)
+			switch {
+			case !hasMin:
+				bounds = "at most " + formatNumber(maxV)
+			case !hasMax:
+				bounds = "at least " + formatNumber(minV)
+			}
+			return "", nil, fmt.Errorf("value %s out of range for %s: must be %s", value, s.EntityID, bounds)
+		}
+		if step, ok := toFloat(attrs["step"]); ok && step > 0 {
+			n := (v - minV) / step
+			if math.Abs(n-math.Round(n)) > 1e-9 {
+				return "", nil, fmt.Errorf("value %s for %s is not a multiple of step %s from %s",
+					value, s.EntityID, formatNumber(step), formatNumber(minV))
+			}
+		}
+		return "set_value", map[string]any{"value": v}, nil
+
+	case "input_text", "text":
+		if minL, ok := toFloat(attrs["min"]); ok && float64(len([]rune(value))) < minL {
+			return "", nil, fmt.Errorf("value for %s is too short: minimum length is %s", s.EntityID, formatNumber(minL))
+		}
+		if maxL, ok := toFloat(attrs["max"]); ok && float64(len([]rune(value))) > maxL {
+			return "", nil, fmt.Errorf("value for %s is too long: maximum length is %s", s.EntityID, formatNumber(maxL))
+		}
+		if pattern, ok := attrs["pattern"].(string); ok && pattern != "" {
+			re, err := regexp.Compile("^(?:" + pattern + ")$")
+			if err == nil && !re.MatchString(value) {
+				return "", nil, fmt.Errorf("value %q for %s does not match pattern %s", value, s.EntityID, pattern)
+			}
+		}
+		return "set_value", map[string]any{"value": value}, nil
+
+	case "input_select", "select":
+		options, _ := attrs["options"].([]any)
+		names := make([]string, 0, len(options))
+		for _, o := range options {
+			name := fmt.Sprint(o)
+			if name == value {
+				return "select_option", map[string]any{"option": value}, nil
+			}
+			names = append(names, name)
+		}
+		return "", nil, fmt.Errorf("invalid option %q for %s\n  options: %s", value, s.EntityID, strings.Join(names, ", "))
+
+	case "input_datetime":
+		hasDate, _ := attrs["has_date"].(bool)
+		hasTime, _ := attrs["has_time"].(bool)
+		return datetimeServiceCall(s.EntityID, value, hasDate, hasTime)
+	}
+	return "", nil, fmt.Errorf("%s is not a helper entity", s.EntityID)
+}
+
+// datetimeServiceCall validates value for an input_datetime with the given
+// capabilities and returns the set_datetime call.
+func datetimeServiceCall(entityID, value string, hasDate, hasTime bool) (string, map[string]any, error) {
+	parse := func(layouts ...string) (time.Time, bool) {
+		for _, l := range layouts {
+			if t, err := time.ParseInLocation(l, value, time.Local); err == nil {
+				return t, true
+			}
+		}
+		return time.Time{}, false
+	}
+	switch {
+	case hasDate && hasTime:
+		t, ok := parse("2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02T15:04:05", "2006-01-02T15:04", time.RFC3339)
+		if !ok {
+			return "", nil, fmt.Errorf("invalid value %q for %s: use \"YYYY-MM-DD HH:MM[:SS]\"", value, entityID)
+		}
+		return "set_datetime", map[string]any{"datetime": t.Format("2006-01-02 15:04:05")}, nil
+	case hasDate:
+		t, ok := parse("2006-01-02")
+		if !ok {
+			return "", nil, fmt.Errorf("invalid value %q for %s: use YYYY-MM-DD", value, entityID)
+		}
+		return "set_datetime", map[string]any{"date": t.Format("2006-01-02")}, nil
+	case hasTime:
+		t, ok := parse("15:04:05", "15:04")
+		if !ok {
+			return "", nil, fmt.Errorf("invalid value %q for %s: use HH:MM[:SS]", value, entityID)
+		}
+		return "set_datetime", map[string]any{"time": t.Format("15:04:05")}, nil
+	}
+	return "", nil, fmt.Errorf("%s has neither a date nor a time", entityID)
+}
+
+// formatNumber formats f without a trailing ".0" for whole numbers.
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
 var (
 	stateListDomain string
 	stateListArea   string
+	stateListWhere  string
+	stateListSort   string
+	stateListLimit  int
 )
 
 var stateListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List entity states, optionally filtered by domain or area",
+	Long: `List entity states, optionally filtered by domain, area, or an expression.
+
+--where takes an expression over entity_id, domain, state, attributes.<name>,
+last_changed and last_updated. Supported operators are == != < <= > >= =~
+(regex), && || ! and parentheses; functions float(), int(), str(), lower(),
+len() and contains(). Durations like -2h compare against the current time.
+
+Examples:
+  hactl state list --domain light --where 'state == "on" && attributes.brightness > 100'
+  hactl state list --where 'attributes.device_class == "battery" && float(state) < 20'
+  hactl state list --where 'last_changed < -2h' --sort -last_changed --limit 10`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		var where *expr.Expr
+		if stateListWhere != "" {
+			var err error
+			where, err = expr.Parse(stateListWhere)
+			if err != nil {
+				return output.Err("invalid --where expression: %s", err)
+			}
+		}
+		if stateListLimit < 0 {
+			return output.Err("--limit must not be negative")
+		}
+
 		states, err := getClient().ListStates()
 		if err != nil {
 			return output.Err("%s", err)
@@ -130,6 +360,23 @@ var stateListCmd = &cobra.Command{
 			states = filtered
 		}
 
+		if where != nil {
+			filtered := states[:0]
+			for _, s := range states {
+				if where.Match(stateEnv(s)) {
+					filtered = append(filtered, s)
+				}
+			}
+			states = filtered
+		}
+
+		if stateListSort != "" {
+			sortStates(states, stateListSort)
+		}
+		if stateListLimit > 0 && len(states) > stateListLimit {
+			states = states[:stateListLimit]
+		}
+
 		if quiet {
 			return nil
 		}
@@ -149,14 +396,63 @@ var stateListCmd = &cobra.Command{
 }
 
 func init() {
+	stateSetCmd.Flags().BoolVar(&stateSetRaw, "raw", false, "POST the state directly instead of calling the helper's service")
+
 	stateListCmd.Flags().StringVar(&stateListDomain, "domain", "", "filter by domain (e.g. light, climate, sensor, switch, binary_sensor)")
 	stateListCmd.Flags().StringVar(&stateListArea, "area", "", "filter by area name")
+	stateListCmd.Flags().StringVar(&stateListWhere, "where", "", "filter by expression over state and attributes (see --help)")
+	stateListCmd.Flags().StringVar(&stateListSort, "sort", "", "sort by field (e.g. state, last_changed, attributes.brightness); prefix with - for descending")
+	stateListCmd.Flags().IntVar(&stateListLimit, "limit", 0, "maximum number of entities to return (0 = no limit)")
 
 	stateCmd.AddCommand(stateGetCmd)
 	stateCmd.AddCommand(stateSetCmd)
 	stateCmd.AddCommand(stateListCmd)
 }
 
+// stateEnv builds the expression environment for a state, used by --where
+// and --sort.
+func stateEnv(s client.State) map[string]any {
+	domain, _, _ := strings.Cut(s.EntityID, ".")
+	attrs := s.Attributes
+	if attrs == nil {
+		attrs = map[string]any{}
+	}
+	return map[string]any{
+		"entity_id":    s.EntityID,
+		"domain":       domain,
+		"state":        s.State,
+		"attributes":   attrs,
+		"last_changed": s.LastChanged,
+		"last_updated": s.LastUpdated,
+	}
+}
+
+// sortStates sorts states in place by the field named in key, a dotted path
+// into stateEnv. A leading "-" sorts descending. Entities missing the field
+// always sort last.
+func sortStates(states []client.State, key string) {
+	desc := strings.HasPrefix(key, "-")
+	path := strings.TrimPrefix(key, "-")
+	vals := make(map[string]any, len(states))
+	for _, s := range states {
+		vals[s.EntityID] = expr.Lookup(stateEnv(s), path)
+	}
+	sort.SliceStable(states, func(i, j int) bool {
+		a, b := vals[states[i].EntityID], vals[states[j].EntityID]
+		if a == nil || b == nil {
+			return a != nil
+		}
+		c, ok := expr.Compare(a, b)
+		if !ok {
+			return false
+		}
+		if desc {
+			return c > 0
+		}
+		return c < 0
+	})
+}
+
 // formatAttrsPlain returns a brief human-readable summary of useful attributes.
 func formatAttrsPlain(attrs map[string]any) string {
 	parts := []string{}
@@ -1,7 +1,10 @@
 package cmd
 
 import (
+	"strings"
 	"testing"
+
+	"github.com/joaobarroca93/hactl/client"
 )
 
 // --- toFloat ---
@@ -114,3 +117,132 @@ func TestServiceControlledHints(t *testing.T) {
 		}
 	}
 }
+
+// --- sortStates ---
+
+func TestSortStates(t *testing.T) {
+	states := []client.State{
+		{EntityID: "light.a", State: "on", Attributes: map[string]any{"brightness": float64(50)}},
+		{EntityID: "light.b", State: "off", Attributes: map[string]any{}},
+		{EntityID: "light.c", State: "on", Attributes: map[string]any{"brightness": float64(200)}},
+	}
+
+	sortStates(states, "attributes.brightness")
+	if got := ids(states); got != "light.a,light.c,light.b" {
+		t.Errorf("ascending sort = %s", got)
+	}
+
+	sortStates(states, "-attributes.brightness")
+	if got := ids(states); got != "light.c,light.a,light.b" {
+		t.Errorf("descending sort = %s (missing values must stay last)", got)
+	}
+
+	sortStates(states, "entity_id")
+	if got := ids(states); got != "light.a,light.b,light.c" {
+		t.Errorf("entity_id sort = %s", got)
+	}
+}
+
+// --- stateEnv ---
+
+func TestStateEnv(t *testing.T) {
+	env := stateEnv(client.State{EntityID: "sensor.phone_battery", State: "15"})
+	if env["domain"] != "sensor" {
+		t.Errorf("domain = %v, want sensor", env["domain"])
+	}
+	if _, ok := env["attributes"].(map[string]any); !ok {
+		t.Error("attributes should never be nil")
+	}
+}
+
+func ids(states []client.State) string {
+	out := make([]string, len(states))
+	for i, s := range states {
+		out[i] = s.EntityID
+	}
+	return strings.Join(out, ",")
+}
+
+// --- helperServiceCall ---
+
+func TestHelperServiceCall(t *testing.T) {
+	number := client.State{EntityID: "input_number.target", Attributes: map[string]any{
+		"min": float64(10), "max": float64(30), "step": float64(0.5),
+	}}
+	text := client.State{EntityID: "input_text.code", Attributes: map[string]any{
+		"min": float64(2), "max": float64(4), "pattern": "[0-9]*",
+	}}
+	sel := client.State{EntityID: "input_select.mode", Attributes: map[string]any{
+		"options": []any{"Home", "Away"},
+	}}
+	dtBoth := client.State{EntityID: "input_datetime.alarm", Attributes: map[string]any{"has_date": true, "has_time": true}}
+	dtTime := client.State{EntityID: "input_datetime.wake", Attributes: map[string]any{"has_date": false, "has_time": true}}
+	boolean := client.State{EntityID: "input_boolean.guest"}
+
+	tests := []struct {
+		name    string
+		state   client.State
+		value   string
+		service string
+		key     string
+		want    any
+		wantErr bool
+	}{
+		{"boolean on", boolean, "on", "turn_on", "", nil, false},
+		{"boolean off", boolean, "OFF", "turn_off", "", nil, false},
+		{"boolean invalid", boolean, "maybe", "", "", nil, true},
+		{"number ok", number, "21.5", "set_value", "value", 21.5, false},
+		{"number below min", number, "5", "", "", nil, true},
+		{"number above max", number, "31", "", "", nil, true},
+		{"number off step", number, "21.3", "", "", nil, true},
+		{"number not numeric", number, "warm", "", "", nil, true},
+		{"text ok", text, "123", "set_value", "value", "123", false},
+		{"text too short", text, "1", "", "", nil, true},
+		{"text too long", text, "12345", "", "", nil, true},
+		{"text pattern", text, "abc", "", "", nil, true},
+		{"select ok", sel, "Away", "select_option", "option", "Away", false},
+		{"select invalid", sel, "Vacation", "", "", nil, true},
+		{"datetime both", dtBoth, "2026-01-02 07:30", "set_datetime", "datetime", "2026-01-02 07:30:00", false},
+		{"datetime time only", dtTime, "07:30", "set_datetime", "time", "07:30:00", false},
+		{"datetime time wrong format", dtTime, "2026-01-02", "", "", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, data, err := helperServiceCall(&tt.state, tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got %s %v", svc, data)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if svc != tt.service {
+				t.Errorf("service = %q, want %q", svc, tt.service)
+			}
+			if tt.key != "" && data[tt.key] != tt.want {
+				t.Errorf("data[%q] = %v, want %v", tt.key, data[tt.key], tt.want)
+			}
+		})
+	}
+}
+
+func TestHelperServiceCallRangeMessage(t *testing.T) {
+	tests := []struct {
+		attrs map[string]any
+		value string
+		want  string
+	}{
+		{map[string]any{"min": float64(5), "max": float64(10)}, "11", "must be between 5 and 10"},
+		{map[string]any{"max": float64(10)}, "11", "must be at most 10"},
+		{map[string]any{"min": float64(5)}, "4", "must be at least 5"},
+	}
+	for _, tt := range tests {
+		s := client.State{EntityID: "number.limit", Attributes: tt.attrs}
+		_, _, err := helperServiceCall(&s, tt.value)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("attrs %v, value %s: err = %v, want %q", tt.attrs, tt.value, err, tt.want)
+		}
+	}
+}
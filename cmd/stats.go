@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/joaobarroca93/hactl/client"
+	"github.com/joaobarroca93/hactl/output"
+	"github.com/spf13/cobra"
+)
+
+// statsPeriods maps the --period values to their adjective in plain output.
+var statsPeriods = map[string]string{
+	"hour":  "hourly",
+	"day":   "daily",
+	"week":  "weekly",
+	"month": "monthly",
+}
+
+// StatsResult is the JSON output of the stats command.
+type StatsResult struct {
+	EntityID   string             `json:"entity_id"`
+	Period     string             `json:"period"`
+	Unit       string             `json:"unit,omitempty"`
+	Statistics []client.Statistic `json:"statistics"`
+}
+
+var statsCmd = &cobra.Command{
+	Use:   "stats <sensor>",
+	Short: "Show long-term statistics for a sensor",
+	Long: `Show the long-term statistics Home Assistant keeps for sensors with a
+state_class, which outlive the recorder's history. Each bucket of --period
+(hour, day, week or month) has the mean, min and max of measurements, or
+the sum, state and change of meters such as energy.
+
+--since is a duration (30d, 12h) or a time in the forms history --start
+accepts ("2026-09-01", "yesterday 18:00").
+
+Examples:
+  hactl stats sensor.living_room_temperature --plain
+  hactl stats sensor.energy_consumption --period month --since 365d
+  hactl stats "outdoor temperature" --period week --since 2026-09-01`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		period, _ := cmd.Flags().GetString("period")
+		if _, ok := statsPeriods[period]; !ok {
+			return output.Err("--period must be one of: hour, day, week, month")
+		}
+		sinceFlag, _ := cmd.Flags().GetString("since")
+		since, err := parseSince(sinceFlag, time.Now())
+		if err != nil {
+			return output.Err("%s", err)
+		}
+		entityID, err := resolveEntity(args[0], "")
+		if err != nil {
+			return output.Err("%s", err)
+		}
+
+		ws, err := dialWS()
+		if err != nil {
+			return output.Err("%s", err)
+		}
+		defer ws.Close()
+		stats, err := ws.StatisticsDuringPeriod([]string{entityID}, period, since)
+		if err != nil {
+			return output.Err("%s", err)
+		}
+
+		res := StatsResult{EntityID: entityID, Period: period, Statistics: stats[entityID]}
+		if res.Statistics == nil {
+			res.Statistics = []client.Statistic{}
+		}
+		if s, err := getClient().GetState(entityID); err == nil {
+			res.Unit, _ = s.Attributes["unit_of_measurement"].(string)
+		}
+
+		if quiet {
+			return nil
+		}
+		if plain {
+			if len(res.Statistics) == 0 {
+				output.PrintPlain(fmt.Sprintf("no statistics for %s since %s (only sensors with a state_class have them)", entityID, since.Local().Format("2006-01-02 15:04")))
+				return nil
+			}
+			for _, st := range res.Statistics {
+				output.PrintPlain(formatStatPlain(st, period, res.Unit))
+			}
+			return nil
+		}
+		return output.PrintJSON(res)
+	},
+}
+
+func init() {
+	statsCmd.Flags().String("period", "day", "bucket size: hour, day, week or month")
+	statsCmd.Flags().String("since", "30d", `start of the statistics: a duration (30d, 12h) or a time ("2026-09-01")`)
+
+	rootCmd.AddCommand(statsCmd)
+}
+
+// parseSince parses --since: a duration before now, or a time as accepted
+// by parseTimeArg.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if d, err := parseHistoryDuration(s); err == nil {
+		if d <= 0 {
+			return time.Time{}, fmt.Errorf("--since must be a positive duration, got: %s", s)
+		}
+		return now.Add(-d), nil
+	}
+	t, err := parseTimeArg(s, now)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --since: %s", err)
+	}
+	if !t.Before(now) {
+		return time.Time{}, fmt.Errorf("--since must be in the past, got: %s", s)
+	}
+	return t, nil
+}
+
+// formatStatPlain returns a one-line summary of a statistics bucket, e.g.
+// "2026-10-17 daily mean 21.3°C (min 18.1, max 23.9)".
+func formatStatPlain(st client.Statistic, period, unit string) string {
+	layout := "2006-01-02"
+	switch period {
+	case "hour":
+		layout = "2006-01-02 15:00"
+	case "month":
+		layout = "2006-01"
+	}
+	prefix := st.Start.Local().Format(layout) + " " + statsPeriods[period]
+
+	switch {
+	case st.Mean != nil:
+		line := fmt.Sprintf("%s mean %s", prefix, withUnit(*st.Mean, unit))
+		var extra []string
+		if st.Min != nil {
+			extra = append(extra, "min "+formatStat(*st.Min))
+		}
+		if st.Max != nil {
+			extra = append(extra, "max "+formatStat(*st.Max))
+		}
+		if len(extra) > 0 {
+			line += " (" + strings.Join(extra, ", ") + ")"
+		}
+		return line
+	case st.Change != nil:
+		line := fmt.Sprintf("%s change %s", prefix, withUnit(*st.Change, unit))
+		if st.State != nil {
+			line += " (state " + formatStat(*st.State) + ")"
+		}
+		return line
+	case st.State != nil:
+		return fmt.Sprintf("%s state %s", prefix, withUnit(*st.State, unit))
+	case st.Sum != nil:
+		return fmt.Sprintf("%s sum %s", prefix, withUnit(*st.Sum, unit))
+	}
+	return prefix + " no data"
+}
+
+// formatStat rounds a statistic to two decimals for display.
+func formatStat(v float64) string {
+	return formatNumber(math.Round(v*100) / 100)
+}
+
+// withUnit appends a unit to a statistic, without a space for degrees and
+// percentages as Home Assistant displays them.
+func withUnit(v float64, unit string) string {
+	switch {
+	case unit == "":
+		return formatStat(v)
+	case strings.HasPrefix(unit, "°") || unit == "%":
+		return formatStat(v) + unit
+	}
+	return formatStat(v) + " " + unit
+}
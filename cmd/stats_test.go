@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/joaobarroca93/hactl/client"
+)
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{in: "30d", want: now.Add(-30 * 24 * time.Hour)},
+		{in: "12h", want: now.Add(-12 * time.Hour)},
+		{in: "2026-09-01T00:00:00Z", want: time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)},
+		{in: "3h ago", want: now.Add(-3 * time.Hour)},
+		{in: "0s", wantErr: true},
+		{in: "-2h", wantErr: true},
+		{in: "2027-01-01T00:00:00Z", wantErr: true},
+		{in: "a while", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseSince(tt.in, now)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseSince(%q) = %v, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseSince(%q): %v", tt.in, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseSince(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFormatStatPlain(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	start := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	day := start.Local().Format("2006-01-02")
+	tests := []struct {
+		name   string
+		st     client.Statistic
+		period string
+		unit   string
+		want   string
+	}{
+		{
+			name:   "measurement",
+			st:     client.Statistic{Start: start, Mean: f(21.3333), Min: f(18.1), Max: f(23.9)},
+			period: "day", unit: "°C",
+			want: day + " daily mean 21.33°C (min 18.1, max 23.9)",
+		},
+		{
+			name:   "meter",
+			st:     client.Statistic{Start: start, Sum: f(812.4), State: f(5310.2), Change: f(12.4)},
+			period: "day", unit: "kWh",
+			want: day + " daily change 12.4 kWh (state 5310.2)",
+		},
+		{
+			name:   "meter without change",
+			st:     client.Statistic{Start: start, Sum: f(812.4)},
+			period: "day", unit: "kWh",
+			want: day + " daily sum 812.4 kWh",
+		},
+		{
+			name:   "percentage by month",
+			st:     client.Statistic{Start: start, Mean: f(54)},
+			period: "month", unit: "%",
+			want: start.Local().Format("2006-01") + " monthly mean 54%",
+		},
+		{
+			name:   "hourly without unit",
+			st:     client.Statistic{Start: start, Mean: f(3), Min: f(1), Max: f(5)},
+			period: "hour",
+			want:   start.Local().Format("2006-01-02 15:00") + " hourly mean 3 (min 1, max 5)",
+		},
+		{
+			name:   "empty bucket",
+			st:     client.Statistic{Start: start},
+			period: "week",
+			want:   day + " weekly no data",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatStatPlain(tt.st, tt.period, tt.unit); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
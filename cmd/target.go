@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/joaobarroca93/hactl/client"
+)
+
+// isGlob reports whether ref is an entity glob such as light.kitchen_*.
+func isGlob(ref string) bool {
+	return strings.ContainsAny(ref, "*?[")
+}
+
+// resolveServiceEntities resolves the --entity values of a service call to
+// entity IDs, in order and without duplicates. Globs are matched against the
+// entity IDs visible through the filter, keeping those of domain when it is
+// set; other references are resolved within domain, so "kitchen" finds
+// light.kitchen for a light service.
+func resolveServiceEntities(refs []string, domain string) ([]string, error) {
+	var ids []string
+	seen := map[string]bool{}
+	add := func(id string) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	for _, ref := range refs {
+		if isGlob(ref) {
+			if _, err := path.Match(ref, ""); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %s", ref, err)
+			}
+			states, err := filteredStates()
+			if err != nil {
+				return nil, err
+			}
+			n := 0
+			for _, s := range states {
+				if domain != "" && !strings.HasPrefix(s.EntityID, domain+".") {
+					continue
+				}
+				if ok, _ := path.Match(ref, s.EntityID); ok {
+					add(s.EntityID)
+					n++
+				}
+			}
+			if n == 0 && domain != "" {
+				return nil, fmt.Errorf("no %s entities match %s", domain, ref)
+			}
+			if n == 0 {
+				return nil, fmt.Errorf("no entities match %s", ref)
+			}
+			continue
+		}
+
+		id, err := resolveEntity(ref, domain)
+		if err != nil && domain != "" && entityIDRe.MatchString(ref) {
+			// Fall through to the domain mismatch check for an explicit
+			// entity ID of another domain.
+			id, err = resolveEntity(ref, "")
+		}
+		if err != nil {
+			return nil, err
+		}
+		add(id)
+	}
+	return ids, nil
+}
+
+// checkServiceDomain rejects an entity whose domain does not match the
+// service's. homeassistant.* services (turn_on, turn_off, toggle) are
+// cross-domain by design.
+func checkServiceDomain(domain, svc, entityID string) error {
+	if domain == "homeassistant" {
+		return nil
+	}
+	entityDomain, _, _ := strings.Cut(entityID, ".")
+	if entityDomain != domain {
+		return fmt.Errorf(
+			"domain mismatch: service %s.%s cannot target a %s entity\n  did you mean: hactl service call %s.%s --entity %s",
+			domain, svc, entityDomain, entityDomain, svc, entityID,
+		)
+	}
+	return nil
+}
+
+// registryTargets holds area, device and label targets resolved to IDs.
+type registryTargets struct {
+	AreaIDs   []string
+	DeviceIDs []string
+	LabelIDs  []string
+}
+
+// resolveRegistryTargets maps --area, --device and --label values to IDs.
+// Each value may be an ID or a name, matched case-insensitively.
+func resolveRegistryTargets(idx *client.TargetIndex, areas, devices, labels []string) (registryTargets, error) {
+	var t registryTargets
+	for _, ref := range areas {
+		id := ""
+		for _, a := range idx.Areas {
+			if strings.EqualFold(a.AreaID, ref) || strings.EqualFold(a.Name, ref) {
+				id = a.AreaID
+				break
+			}
+		}
+		if id == "" {
+			return t, fmt.Errorf("area not found: %s", ref)
+		}
+		t.AreaIDs = append(t.AreaIDs, id)
+	}
+	for _, ref := range devices {
+		id := ""
+		for _, d := range idx.Devices {
+			if d.ID == ref || strings.EqualFold(d.Name, ref) || d.NameByUser != "" && strings.EqualFold(d.NameByUser, ref) {
+				id = d.ID
+				break
+			}
+		}
+		if id == "" {
+			return t, fmt.Errorf("device not found: %s", ref)
+		}
+		t.DeviceIDs = append(t.DeviceIDs, id)
+	}
+	for _, ref := range labels {
+		id := ""
+		for _, l := range idx.Labels {
+			if strings.EqualFold(l.LabelID, ref) || strings.EqualFold(l.Name, ref) {
+				id = l.LabelID
+				break
+			}
+		}
+		if id == "" {
+			return t, fmt.Errorf("label not found: %s", ref)
+		}
+		t.LabelIDs = append(t.LabelIDs, id)
+	}
+	return t, nil
+}
+
+// targetMembers returns the entities of domain that belong to any of the
+// targets, the way Home Assistant expands a service target. An empty domain
+// matches every domain.
+func targetMembers(idx *client.TargetIndex, t registryTargets, domain string) []string {
+	in := func(list []string, s string) bool {
+		return s != "" && containsString(list, s)
+	}
+	var ids []string
+	for _, e := range idx.Entities {
+		if domain != "" && !strings.HasPrefix(e.EntityID, domain+".") {
+			continue
+		}
+		member := in(t.AreaIDs, e.AreaID) || in(t.DeviceIDs, e.DeviceID)
+		for _, l := range e.Labels {
+			member = member || in(t.LabelIDs, l)
+		}
+		if member {
+			ids = append(ids, e.EntityID)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/joaobarroca93/hactl/client"
+	"github.com/joaobarroca93/hactl/filter"
+)
+
+// --- resolveServiceEntities ---
+
+func TestResolveServiceEntities(t *testing.T) {
+	useFilter(t, filter.New("all", true))
+	useResolverStates(t, resolverFixture())
+
+	tests := []struct {
+		refs        []string
+		domain      string
+		want        string
+		errContains string
+	}{
+		{[]string{"light.bedroom"}, "light", "light.bedroom", ""},
+		{[]string{"light.bedroom", "cabinets"}, "light", "light.bedroom,light.kitchen_cabinets", ""},
+		{[]string{"light.kitchen_*"}, "light", "light.kitchen_cabinets", ""},
+		{[]string{"light.*", "light.bedroom"}, "light", "light.shelly_abc123_channel_1,light.kitchen_cabinets,light.bedroom", ""},
+		{[]string{"*kitchen*"}, "light", "light.kitchen_cabinets", ""},
+		{[]string{"*kitchen*"}, "switch", "switch.kitchen_light", ""},
+		{[]string{"*kitchen*"}, "", "light.kitchen_cabinets,switch.kitchen_light", ""},
+		{[]string{"*kitchen*"}, "cover", "", "no cover entities match *kitchen*"},
+		{[]string{"switch.kitchen_light"}, "light", "switch.kitchen_light", ""}, // mismatch is checked by the caller
+		{[]string{"cover.*"}, "cover", "", "no cover entities match cover.*"},
+		{[]string{"cover.*"}, "", "", "no entities match cover.*"},
+		{[]string{"light.["}, "light", "", "invalid pattern"},
+	}
+	for _, tt := range tests {
+		got, err := resolveServiceEntities(tt.refs, tt.domain)
+		if tt.errContains != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("resolveServiceEntities(%v) error = %v, want %q", tt.refs, err, tt.errContains)
+			}
+			continue
+		}
+		if err != nil || strings.Join(got, ",") != tt.want {
+			t.Errorf("resolveServiceEntities(%v) = %v, %v; want %q", tt.refs, got, err, tt.want)
+		}
+	}
+}
+
+func TestCheckServiceDomain(t *testing.T) {
+	if err := checkServiceDomain("light", "turn_on", "light.kitchen"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := checkServiceDomain("homeassistant", "turn_on", "switch.fan"); err != nil {
+		t.Errorf("homeassistant.* must accept any domain: %v", err)
+	}
+	err := checkServiceDomain("light", "turn_on", "switch.fan")
+	if err == nil || !strings.Contains(err.Error(), "domain mismatch") {
+		t.Errorf("got %v, want domain mismatch", err)
+	}
+}
+
+// --- registry targets ---
+
+func targetIndexFixture() *client.TargetIndex {
+	return &client.TargetIndex{
+		Areas: []client.Area{
+			{AreaID: "kitchen", Name: "Kitchen"},
+			{AreaID: "living_room", Name: "Living Room"},
+		},
+		Devices: []client.Device{
+			{ID: "dev1", Name: "Hue Bridge", AreaID: "living_room"},
+			{ID: "dev2", Name: "Shelly 1", NameByUser: "Kitchen Relay", AreaID: "kitchen"},
+		},
+		Labels: []client.Label{{LabelID: "night", Name: "Night mode"}},
+		Entities: []client.EntityTarget{
+			{EntityID: "light.kitchen", DeviceID: "dev2", AreaID: "kitchen"},
+			{EntityID: "switch.kitchen_relay", DeviceID: "dev2", AreaID: "kitchen"},
+			{EntityID: "light.sofa", DeviceID: "dev1", AreaID: "living_room", Labels: []string{"night"}},
+			{EntityID: "light.tv", DeviceID: "dev1", AreaID: "living_room"},
+		},
+	}
+}
+
+func TestResolveRegistryTargets(t *testing.T) {
+	idx := targetIndexFixture()
+	got, err := resolveRegistryTargets(idx, []string{"Living Room"}, []string{"kitchen relay"}, []string{"night"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(got.AreaIDs, ",") != "living_room" || strings.Join(got.DeviceIDs, ",") != "dev2" || strings.Join(got.LabelIDs, ",") != "night" {
+		t.Errorf("got %+v", got)
+	}
+
+	for _, tt := range []struct {
+		areas, devices, labels []string
+		want                   string
+	}{
+		{[]string{"garage"}, nil, nil, "area not found: garage"},
+		{nil, []string{"dev9"}, nil, "device not found: dev9"},
+		{nil, nil, []string{"party"}, "label not found: party"},
+	} {
+		_, err := resolveRegistryTargets(idx, tt.areas, tt.devices, tt.labels)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("got %v, want %q", err, tt.want)
+		}
+	}
+}
+
+func TestTargetMembers(t *testing.T) {
+	idx := targetIndexFixture()
+	tests := []struct {
+		targets registryTargets
+		domain  string
+		want    string
+	}{
+		{registryTargets{AreaIDs: []string{"kitchen"}}, "light", "light.kitchen"},
+		{registryTargets{AreaIDs: []string{"kitchen"}}, "", "light.kitchen,switch.kitchen_relay"},
+		{registryTargets{DeviceIDs: []string{"dev1"}}, "light", "light.sofa,light.tv"},
+		{registryTargets{LabelIDs: []string{"night"}}, "light", "light.sofa"},
+		{registryTargets{AreaIDs: []string{"kitchen"}, LabelIDs: []string{"night"}}, "light", "light.kitchen,light.sofa"},
+		{registryTargets{AreaIDs: []string{"living_room"}}, "switch", ""},
+	}
+	for _, tt := range tests {
+		got := strings.Join(targetMembers(idx, tt.targets, tt.domain), ",")
+		if got != tt.want {
+			t.Errorf("targetMembers(%+v, %q) = %q, want %q", tt.targets, tt.domain, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/joaobarroca93/hactl/output"
+	"github.com/spf13/cobra"
+)
+
+var templateCmd = &cobra.Command{
+	Use:   "template",
+	Short: "Render Home Assistant templates",
+}
+
+var templateRenderCmd = &cobra.Command{
+	Use:   "render [template]",
+	Short: "Render a Jinja template in Home Assistant",
+	Long: `Render a Jinja template with Home Assistant's template engine.
+
+In filter.mode: exposed, templates are checked before they are sent: every
+entity they reference must be visible through the filter, entity IDs must be
+written as complete lowercase string literals, and constructs that enumerate entities (bare
+states, states.<domain>, expand, area_entities, …) are refused.
+
+Examples:
+  hactl template render '{{ states("sensor.outdoor_temperature") | float * 2 }}'
+  hactl template render -f report.j2
+  cat report.j2 | hactl template render -f -`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, _ := cmd.Flags().GetString("file")
+		if (file == "") == (len(args) == 0) {
+			return output.Err("give either a template argument or --file")
+		}
+
+		tpl := ""
+		if len(args) == 1 {
+			tpl = args[0]
+		} else {
+			data, err := readFileOrStdin(file)
+			if err != nil {
+				return output.Err("read template: %s", err)
+			}
+			tpl = string(data)
+		}
+
+		if entityFilter.Mode() == "exposed" {
+			if err := checkTemplateAccess(tpl, entityFilter.IsAllowed); err != nil {
+				return output.Err("%s", err)
+			}
+		}
+
+		result, err := getClient().RenderTemplate(tpl)
+		if err != nil {
+			return output.Err("%s", err)
+		}
+		if quiet {
+			return nil
+		}
+		if plain {
+			output.PrintPlain(result)
+			return nil
+		}
+		return output.PrintJSON(map[string]string{"result": result})
+	},
+}
+
+func init() {
+	templateRenderCmd.Flags().StringP("file", "f", "", "read the template from a file (- for stdin)")
+
+	templateCmd.AddCommand(templateRenderCmd)
+	rootCmd.AddCommand(templateCmd)
+}
+
+// readFileOrStdin reads path, or stdin when path is "-".
+func readFileOrStdin(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+var (
+	// tplStringRe matches single- or double-quoted string literals.
+	tplStringRe = regexp.MustCompile(`'(?:[^'\\]|\\.)*'|"(?:[^"\\]|\\.)*"`)
+
+	// tplEntityLiteralRe matches literal contents shaped like an entity ID,
+	// and tplPartialEntityRe matches a domain prefix used to build one. Both
+	// ignore case, as Home Assistant lowercases entity IDs on lookup.
+	tplEntityLiteralRe = regexp.MustCompile(`(?i)^[a-z_]+\.[a-z0-9_]+$`)
+	tplPartialEntityRe = regexp.MustCompile(`(?i)^[a-z_]+\.[a-z0-9_]*$`)
+
+	// tplStatesRe matches every use of the states object. Group 1 is set for
+	// the call form states(…), groups 2 and 3 for states.<domain>.<object>.
+	tplStatesRe = regexp.MustCompile(`\bstates\b(?:(\s*\()|\.([a-z_]+)\.([a-z0-9_]+))?`)
+
+	// tplEnumeratingRe matches functions and filters that list entities.
+	tplEnumeratingRe = regexp.MustCompile(`\b(expand|closest|area_entities|device_entities|floor_entities|integration_entities|label_entities)\b`)
+
+	// tplEntityFuncRe matches functions whose first argument is an entity ID,
+	// used either as a call or as a filter.
+	tplEntityFuncRe = regexp.MustCompile(`(\|\s*)?\b(is_state|state_attr|is_state_attr|has_value|state_translated|device_id|area_id|area_name|labels)\b(\s*\()?`)
+
+	// tplDistanceRe matches distance, whose arguments are entity IDs, states
+	// or coordinates, used either as a call or as a filter.
+	tplDistanceRe = regexp.MustCompile(`(\|\s*)?\bdistance\b(\s*\()?`)
+
+	// tplLiteralArgRe matches an argument that cannot address an entity
+	// dynamically: a string literal (blanked), a number, or states.<d>.<o>.
+	tplLiteralArgRe = regexp.MustCompile(`^(?:'\s*'|"\s*"|-?\d+(?:\.\d+)?|states\.[a-z_]+\.[a-z0-9_]+)$`)
+
+	// tplEntityArgRe matches an argument that is a single entity ID: a string
+	// literal (blanked) or states.<d>.<o>.
+	tplEntityArgRe = regexp.MustCompile(`^(?:'\s*'|"\s*"|states\.[a-z_]+\.[a-z0-9_]+)$`)
+)
+
+// checkTemplateAccess statically checks that tpl can only read entities for
+// which allowed returns true. It is deliberately strict: anything that could
+// address an entity dynamically is refused, because the filter cannot see
+// what it would resolve to.
+func checkTemplateAccess(tpl string, allowed func(string) bool) error {
+	for _, lit := range tplStringRe.FindAllString(tpl, -1) {
+		val := lit[1 : len(lit)-1]
+		if tplEntityLiteralRe.MatchString(val) {
+			if id := strings.ToLower(val); !allowed(id) {
+				return fmt.Errorf("entity not found: %s", id)
+			}
+			if val != strings.ToLower(val) {
+				return fmt.Errorf("entity IDs must be written in lowercase in exposed mode, got %q", val)
+			}
+		} else if tplPartialEntityRe.MatchString(val) {
+			return fmt.Errorf("template builds an entity ID from %q; entity IDs must be complete string literals in exposed mode", val)
+		}
+	}
+
+	// Check identifiers with string literals blanked out, so text inside
+	// strings is never mistaken for code.
+	code := tplStringRe.ReplaceAllStringFunc(tpl, func(lit string) string {
+		return lit[:1] + strings.Repeat(" ", len(lit)-2) + lit[:1]
+	})
+
+	if m := tplEnumeratingRe.FindStringSubmatch(code); m != nil {
+		return fmt.Errorf("%s() is not permitted in exposed mode: it can list entities outside the filter", m[1])
+	}
+
+	for _, m := range tplStatesRe.FindAllStringSubmatchIndex(code, -1) {
+		switch {
+		case m[2] >= 0: // states(…)
+			if err := requireLiteralArg(code, m[1], "states"); err != nil {
+				return err
+			}
+		case m[4] >= 0: // states.<domain>.<object>
+			id := code[m[4]:m[5]] + "." + code[m[6]:m[7]]
+			if !allowed(id) {
+				return fmt.Errorf("entity not found: %s", id)
+			}
+		default:
+			return fmt.Errorf("iterating over states is not permitted in exposed mode; reference entities by ID")
+		}
+	}
+
+	for _, m := range tplEntityFuncRe.FindAllStringSubmatchIndex(code, -1) {
+		name := code[m[4]:m[5]]
+		if m[2] >= 0 {
+			return fmt.Errorf("%s must be called as a function with a literal entity ID in exposed mode", name)
+		}
+		if m[6] >= 0 {
+			if err := requireLiteralArg(code, m[1], name); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, m := range tplDistanceRe.FindAllStringSubmatchIndex(code, -1) {
+		if m[2] >= 0 {
+			return fmt.Errorf("distance must be called as a function with literal arguments in exposed mode")
+		}
+		if m[4] >= 0 {
+			if err := requireLiteralArgs(code, m[1], "distance"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// callArgs splits the arguments of the call whose opening parenthesis ends
+// at pos, skipping over nested brackets. ok is false when the call is not
+// closed.
+func callArgs(code string, pos int) (args []string, ok bool) {
+	depth, start := 0, pos
+	for i := pos; i < len(code); i++ {
+		c := code[i]
+		switch {
+		case c == '(' || c == '[' || c == '{':
+			depth++
+		case depth > 0 && (c == ')' || c == ']' || c == '}'):
+			depth--
+		case depth == 0 && (c == ',' || c == ')'):
+			args = append(args, strings.TrimSpace(code[start:i]))
+			if c == ')' {
+				return args, true
+			}
+			start = i + 1
+		}
+	}
+	return nil, false
+}
+
+// requireLiteralArgs checks that every argument of the call whose opening
+// parenthesis ends at pos is a string literal, a number or states.<d>.<o>.
+// String literals are expected blanked out, as checkTemplateAccess does.
+func requireLiteralArgs(code string, pos int, name string) error {
+	args, ok := callArgs(code, pos)
+	if !ok {
+		return fmt.Errorf("%s() must be given literal entity IDs or coordinates in exposed mode", name)
+	}
+	if len(args) == 1 && args[0] == "" {
+		return nil
+	}
+	for _, arg := range args {
+		if !tplLiteralArgRe.MatchString(arg) {
+			return fmt.Errorf("%s() must be given literal entity IDs or coordinates in exposed mode", name)
+		}
+	}
+	return nil
+}
+
+// requireLiteralArg checks that the first argument of the call whose
+// opening parenthesis ends at pos is exactly one string literal or
+// states.<d>.<o>, with nothing concatenated or filtered onto it.
+func requireLiteralArg(code string, pos int, name string) error {
+	args, ok := callArgs(code, pos)
+	if !ok || !tplEntityArgRe.MatchString(args[0]) {
+		return fmt.Errorf("%s() must be given a literal entity ID in exposed mode", name)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"testing"
+)
+
+// --- checkTemplateAccess ---
+
+func TestCheckTemplateAccess(t *testing.T) {
+	allowed := func(id string) bool {
+		return id == "sensor.outdoor_temperature" || id == "light.kitchen"
+	}
+	ok := []string{
+		`{{ states("sensor.outdoor_temperature") | float * 2 }}`,
+		`{{ states('light.kitchen') }}`,
+		`{{ is_state('light.kitchen', 'on') }}`,
+		`{{ state_attr("light.kitchen", "brightness") }}`,
+		`{{ states.light.kitchen.state }}`,
+		`{{ states.sensor.outdoor_temperature.attributes.unit_of_measurement }}`,
+		`{{ now().strftime('%H:%M') }}`,
+		`{{ 1.5 * 2 }}`,
+		`It says "states are great" in a string`,
+		`{{ distance('light.kitchen') | round(1) }}`,
+		`{{ distance(52.37, 4.89, "light.kitchen") }}`,
+		`{{ distance(states.light.kitchen, -33.9, 151.2) }}`,
+		`{{ "distance(x) in a string" }}`,
+	}
+	for _, tpl := range ok {
+		if err := checkTemplateAccess(tpl, allowed); err != nil {
+			t.Errorf("checkTemplateAccess(%q) = %v, want nil", tpl, err)
+		}
+	}
+
+	refused := []string{
+		`{{ states("sensor.secret") }}`,
+		`{{ states.lock.front_door.state }}`,
+		`{{ is_state('lock.front_door', 'locked') }}`,
+		`{{ states | list | count }}`,
+		`{{ states.light | map(attribute='entity_id') | list }}`,
+		`{% for s in states %}{{ s.entity_id }}{% endfor %}`,
+		`{{ states['lock.front_door'] }}`,
+		`{{ expand('group.all') }}`,
+		`{{ area_entities('kitchen') }}`,
+		`{{ integration_entities('hue') }}`,
+		`{% set id = 'lock.' ~ 'front_door' %}{{ states(id) }}`,
+		`{{ states(x) }}`,
+		`{{ 'lock.front_door' | is_state('locked') }}`,
+		`{{ x | state_attr('brightness') }}`,
+		`{{ distance('lock.front_door') }}`,
+		`{{ distance(states.lock.front_door) }}`,
+		`{% set id = 'device_tracker.' ~ 'phone' %}{{ distance(id) }}`,
+		`{{ distance(tracker) }}`,
+		`{{ distance(52.37, 4.89, tracker) }}`,
+		`{{ distance(states[tracker]) }}`,
+		`{{ tracker | distance }}`,
+		`{{ distance('light.kitchen'`,
+		`{{ states('lock' ~ '.front_door') }}`,
+		`{{ states('LOCK.FRONT_DOOR') }}`,
+		`{{ is_state('Lock.Front_Door','locked') }}`,
+		`{{ state_attr('lock' ~ '.front_door','x') }}`,
+		`{{ states('Light.Kitchen') }}`,
+		`{{ states('light.kitchen' | lower) }}`,
+		`{{ is_state('light.' + 'kitchen', 'on') }}`,
+	}
+	for _, tpl := range refused {
+		if err := checkTemplateAccess(tpl, allowed); err == nil {
+			t.Errorf("checkTemplateAccess(%q) = nil, want error", tpl)
+		}
+	}
+}
@@ -15,15 +15,15 @@ var todoCmd = &cobra.Command{
 }
 
 var todoListCmd = &cobra.Command{
-	Use:   "list [entity_id]",
+	Use:   "list [list]",
 	Short: "List items in a todo list (or all todo lists if no entity given)",
 	Args:  cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		var entityIDs []string
 		if len(args) == 1 {
-			eid := ensureTodoPrefix(args[0])
-			if !entityFilter.IsAllowed(eid) {
-				return output.Err("entity not found: %s", eid)
+			eid, err := resolveEntity(args[0], "todo")
+			if err != nil {
+				return output.Err("%s", err)
 			}
 			entityIDs = append(entityIDs, eid)
 		} else {
@@ -86,16 +86,16 @@ var todoListCmd = &cobra.Command{
 }
 
 var todoAddCmd = &cobra.Command{
-	Use:   "add <entity_id> <item>",
+	Use:   "add <list> <item>",
 	Short: "Add an item to a todo list",
 	Args:  cobra.ExactArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		entityID := ensureTodoPrefix(args[0])
-		item := args[1]
-		if !entityFilter.IsAllowed(entityID) {
-			return output.Err("entity not found: %s", entityID)
+		entityID, err := resolveEntity(args[0], "todo")
+		if err != nil {
+			return output.Err("%s", err)
 		}
-		_, err := getClient().CallService("todo", "add_item", map[string]any{
+		item := args[1]
+		_, err = getClient().CallService("todo", "add_item", map[string]any{
 			"entity_id": entityID,
 			"item":      item,
 		})
@@ -114,16 +114,16 @@ var todoAddCmd = &cobra.Command{
 }
 
 var todoDoneCmd = &cobra.Command{
-	Use:   "done <entity_id> <item>",
+	Use:   "done <list> <item>",
 	Short: "Mark a todo item as completed",
 	Args:  cobra.ExactArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		entityID := ensureTodoPrefix(args[0])
-		item := args[1]
-		if !entityFilter.IsAllowed(entityID) {
-			return output.Err("entity not found: %s", entityID)
+		entityID, err := resolveEntity(args[0], "todo")
+		if err != nil {
+			return output.Err("%s", err)
 		}
-		_, err := getClient().CallService("todo", "update_item", map[string]any{
+		item := args[1]
+		_, err = getClient().CallService("todo", "update_item", map[string]any{
 			"entity_id": entityID,
 			"item":      item,
 			"status":    "completed",
@@ -143,16 +143,16 @@ var todoDoneCmd = &cobra.Command{
 }
 
 var todoRemoveCmd = &cobra.Command{
-	Use:   "remove <entity_id> <item>",
+	Use:   "remove <list> <item>",
 	Short: "Remove an item from a todo list",
 	Args:  cobra.ExactArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		entityID := ensureTodoPrefix(args[0])
-		item := args[1]
-		if !entityFilter.IsAllowed(entityID) {
-			return output.Err("entity not found: %s", entityID)
+		entityID, err := resolveEntity(args[0], "todo")
+		if err != nil {
+			return output.Err("%s", err)
 		}
-		_, err := getClient().CallService("todo", "remove_item", map[string]any{
+		item := args[1]
+		_, err = getClient().CallService("todo", "remove_item", map[string]any{
 			"entity_id": entityID,
 			"item":      item,
 		})
@@ -177,11 +177,3 @@ func init() {
 	todoCmd.AddCommand(todoRemoveCmd)
 	rootCmd.AddCommand(todoCmd)
 }
-
-// ensureTodoPrefix adds the "todo." prefix if not already present.
-func ensureTodoPrefix(id string) string {
-	if strings.HasPrefix(id, "todo.") {
-		return id
-	}
-	return "todo." + id
-}
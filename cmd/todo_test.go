@@ -1,10 +1,21 @@
 package cmd
 
-import "testing"
+import (
+	"testing"
 
-// --- ensureTodoPrefix ---
+	"github.com/joaobarroca93/hactl/client"
+	"github.com/joaobarroca93/hactl/filter"
+)
+
+// --- todo list references ---
+
+func TestResolveTodoPrefix(t *testing.T) {
+	useFilter(t, filter.New("all", true))
+	useResolverStates(t, []client.State{
+		{EntityID: "todo.shopping_list"},
+		{EntityID: "todo.to_do_list"},
+	})
 
-func TestEnsureTodoPrefix(t *testing.T) {
 	tests := []struct {
 		input string
 		want  string
@@ -15,9 +26,13 @@ func TestEnsureTodoPrefix(t *testing.T) {
 		{"todo.to_do_list", "todo.to_do_list"},
 	}
 	for _, tt := range tests {
-		got := ensureTodoPrefix(tt.input)
+		got, err := resolveEntity(tt.input, "todo")
+		if err != nil {
+			t.Errorf("resolveEntity(%q, todo): %v", tt.input, err)
+			continue
+		}
 		if got != tt.want {
-			t.Errorf("ensureTodoPrefix(%q) = %q, want %q", tt.input, got, tt.want)
+			t.Errorf("resolveEntity(%q, todo) = %q, want %q", tt.input, got, tt.want)
 		}
 	}
 }
@@ -0,0 +1,381 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/joaobarroca93/hactl/output"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// exposedTriggerPlatforms are the trigger platforms allowed in exposed mode.
+// Their entity references are explicit and can be checked against the
+// filter; platforms such as event or device could observe hidden entities.
+var exposedTriggerPlatforms = map[string]bool{
+	"state":         true,
+	"numeric_state": true,
+	"template":      true,
+	"time":          true,
+	"time_pattern":  true,
+	"sun":           true,
+	"zone":          true,
+	"calendar":      true,
+}
+
+// triggerEntityKeys are the trigger options whose values are entity IDs.
+var triggerEntityKeys = map[string]bool{
+	"entity_id": true,
+	"zone":      true,
+}
+
+var triggerCmd = &cobra.Command{
+	Use:   "trigger",
+	Short: "Watch Home Assistant automation triggers",
+}
+
+var triggerWatchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Stream trigger firings to stdout as JSON lines",
+	Long: `Subscribe to automation triggers (WebSocket subscribe_trigger) and print
+the trigger variables each time one fires. Anything an automation can
+trigger on works: numeric_state above/below, state with for, time patterns,
+zone enter/leave, templates, sun, calendar.
+
+Triggers come from --file (YAML or JSON, a trigger or a list of them, - for
+stdin) in the format of an automation's trigger section, or from flags:
+
+  --entity with --to, --from, --for, --attribute   state trigger
+  --entity with --above and/or --below             numeric_state trigger
+  --at HH:MM[:SS] (repeatable)                     time trigger
+
+In filter.mode: exposed, every entity a trigger refers to must be visible
+through the filter, templates are checked as for template render, and only
+the state, numeric_state, template, time, time_pattern, sun, zone and
+calendar platforms are allowed.
+
+--count and --timeout bound the watch as for events watch: hactl exits 2 if
+--timeout elapses before --count firings.
+
+Examples:
+  hactl trigger watch --entity binary_sensor.door --to on --for 30s
+  hactl trigger watch --entity sensor.outdoor_temperature --below 0
+  hactl trigger watch --at 07:30 --count 1
+  hactl trigger watch -f trigger.yaml --plain`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		triggers, err := readTriggers(cmd)
+		if err != nil {
+			return output.Err("%s", err)
+		}
+		if entityFilter.Mode() == "exposed" {
+			if err := checkTriggerAccess(triggers, entityFilter.IsAllowed); err != nil {
+				return output.Err("%s", err)
+			}
+		}
+		count, _ := cmd.Flags().GetInt("count")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+
+		ws, err := dialWS()
+		if err != nil {
+			return output.Err("%s", err)
+		}
+		sess := ws.StartSession()
+		defer sess.Close()
+
+		sub, err := sess.Subscribe(map[string]any{
+			"type":    "subscribe_trigger",
+			"trigger": triggers,
+		})
+		if err != nil {
+			return output.Err("%s", err)
+		}
+
+		if !quiet {
+			fmt.Fprintf(os.Stderr, "connected, watching %d trigger%s (Ctrl-C to stop)...\n", len(triggers), plural(len(triggers)))
+		}
+
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+		defer signal.Stop(sigCh)
+
+		var deadline <-chan time.Time
+		if timeout > 0 {
+			t := time.NewTimer(timeout)
+			defer t.Stop()
+			deadline = t.C
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+		fired := 0
+		for {
+			select {
+			case raw, ok := <-sub.C:
+				if !ok {
+					return output.Err("websocket closed: %v", sess.Err())
+				}
+				var frame struct {
+					Event struct {
+						Variables map[string]any `json:"variables"`
+					} `json:"event"`
+				}
+				if err := json.Unmarshal(raw, &frame); err != nil || frame.Event.Variables == nil {
+					continue
+				}
+				vars := frame.Event.Variables
+				if !quiet {
+					if plain {
+						fmt.Println(formatTriggerPlain(vars))
+					} else {
+						_ = enc.Encode(vars)
+					}
+				}
+				fired++
+				if count > 0 && fired >= count {
+					return nil
+				}
+			case <-deadline:
+				if count > 0 {
+					return output.Timeout("%d of %d trigger firings within %s", fired, count, timeout)
+				}
+				return nil
+			case <-sigCh:
+				return nil
+			}
+		}
+	},
+}
+
+func init() {
+	triggerWatchCmd.Flags().StringP("file", "f", "", "read triggers from a YAML or JSON file (- for stdin)")
+	addTriggerFlags(triggerWatchCmd)
+	triggerWatchCmd.Flags().Int("count", 0, "exit after this many trigger firings")
+	triggerWatchCmd.Flags().Duration("timeout", 0, "stop after this long; exit 2 if --count was not reached")
+
+	triggerCmd.AddCommand(triggerWatchCmd)
+	rootCmd.AddCommand(triggerCmd)
+}
+
+// addTriggerFlags registers the flags that build a trigger inline.
+func addTriggerFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArray("entity", nil, "entity to trigger on: entity ID, friendly name or glob (repeatable)")
+	cmd.Flags().String("to", "", "state trigger: new state")
+	cmd.Flags().String("from", "", "state trigger: previous state")
+	cmd.Flags().Duration("for", 0, "require the new state to hold this long")
+	cmd.Flags().String("attribute", "", "trigger on this attribute instead of the state")
+	cmd.Flags().Float64("above", 0, "numeric_state trigger: fire when the value rises above this")
+	cmd.Flags().Float64("below", 0, "numeric_state trigger: fire when the value drops below this")
+	cmd.Flags().StringArray("at", nil, "time trigger: HH:MM[:SS] or an input_datetime entity (repeatable)")
+}
+
+// readTriggers returns the triggers given with --file or the inline flags.
+func readTriggers(cmd *cobra.Command) ([]map[string]any, error) {
+	file, _ := cmd.Flags().GetString("file")
+	inline := false
+	for _, name := range []string{"entity", "to", "from", "for", "attribute", "above", "below", "at"} {
+		if cmd.Flags().Changed(name) {
+			inline = true
+		}
+	}
+	switch {
+	case file != "" && inline:
+		return nil, fmt.Errorf("give either --file or trigger flags, not both")
+	case file != "":
+		data, err := readFileOrStdin(file)
+		if err != nil {
+			return nil, fmt.Errorf("read triggers: %s", err)
+		}
+		return parseTriggers(data)
+	case inline:
+		return inlineTriggers(cmd)
+	}
+	return nil, fmt.Errorf("nothing to watch: give --file, --entity or --at")
+}
+
+// parseTriggers parses a trigger or a list of triggers in automation
+// syntax. A mapping with a "triggers" or "trigger" list is accepted too, so
+// an automation's trigger section can be used as-is.
+func parseTriggers(data []byte) ([]map[string]any, error) {
+	var doc any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid trigger file: %s", err)
+	}
+	if m, ok := doc.(map[string]any); ok {
+		for _, key := range []string{"triggers", "trigger"} {
+			if list, ok := m[key].([]any); ok {
+				doc = list
+				break
+			}
+		}
+	}
+	var items []any
+	switch v := doc.(type) {
+	case []any:
+		items = v
+	case map[string]any:
+		items = []any{v}
+	default:
+		return nil, fmt.Errorf("invalid trigger file: expected a trigger or a list of triggers")
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("invalid trigger file: no triggers")
+	}
+	triggers := make([]map[string]any, 0, len(items))
+	for i, item := range items {
+		t, ok := item.(map[string]any)
+		if !ok || triggerPlatform(t) == "" {
+			return nil, fmt.Errorf("trigger %d: expected a mapping with a trigger or platform key", i+1)
+		}
+		triggers = append(triggers, t)
+	}
+	return triggers, nil
+}
+
+// inlineTriggers builds state, numeric_state and time triggers from flags.
+func inlineTriggers(cmd *cobra.Command) ([]map[string]any, error) {
+	refs, _ := cmd.Flags().GetStringArray("entity")
+	times, _ := cmd.Flags().GetStringArray("at")
+	numeric := cmd.Flags().Changed("above") || cmd.Flags().Changed("below")
+
+	var triggers []map[string]any
+	if len(refs) > 0 {
+		entities, err := resolveServiceEntities(refs, "")
+		if err != nil {
+			return nil, err
+		}
+		t := map[string]any{"platform": "state", "entity_id": entities}
+		if numeric {
+			t["platform"] = "numeric_state"
+			for _, name := range []string{"above", "below"} {
+				if cmd.Flags().Changed(name) {
+					v, _ := cmd.Flags().GetFloat64(name)
+					t[name] = v
+				}
+			}
+		}
+		for _, name := range []string{"to", "from"} {
+			if cmd.Flags().Changed(name) {
+				if numeric {
+					return nil, fmt.Errorf("--%s cannot be combined with --above or --below", name)
+				}
+				v, _ := cmd.Flags().GetString(name)
+				t[name] = v
+			}
+		}
+		if attr, _ := cmd.Flags().GetString("attribute"); attr != "" {
+			t["attribute"] = attr
+		}
+		if hold, _ := cmd.Flags().GetDuration("for"); hold > 0 {
+			t["for"] = map[string]any{"seconds": hold.Seconds()}
+		}
+		triggers = append(triggers, t)
+	} else {
+		for _, name := range []string{"to", "from", "for", "attribute", "above", "below"} {
+			if cmd.Flags().Changed(name) {
+				return nil, fmt.Errorf("--%s needs --entity", name)
+			}
+		}
+	}
+
+	if len(times) > 0 {
+		at := make([]any, 0, len(times))
+		for _, s := range times {
+			if entityIDRe.MatchString(s) {
+				at = append(at, s)
+				continue
+			}
+			if _, err := time.Parse("15:04:05", s); err != nil {
+				if _, err := time.Parse("15:04", s); err != nil {
+					return nil, fmt.Errorf("invalid --at %q: use HH:MM, HH:MM:SS or an entity ID", s)
+				}
+			}
+			at = append(at, s)
+		}
+		triggers = append(triggers, map[string]any{"platform": "time", "at": at})
+	}
+	return triggers, nil
+}
+
+// triggerPlatform returns a trigger's platform, given by the "trigger" key
+// in current automation syntax or "platform" in older configurations.
+func triggerPlatform(t map[string]any) string {
+	if p, ok := t["trigger"].(string); ok {
+		return p
+	}
+	p, _ := t["platform"].(string)
+	return p
+}
+
+// checkTriggerAccess checks that triggers only refer to entities for which
+// allowed returns true, and only use platforms whose references can be
+// checked. A hidden entity is reported as not found.
+func checkTriggerAccess(triggers []map[string]any, allowed func(string) bool) error {
+	for _, t := range triggers {
+		p := triggerPlatform(t)
+		if !exposedTriggerPlatforms[p] {
+			return fmt.Errorf("trigger platform %s is not permitted in exposed mode", p)
+		}
+		if err := checkTriggerValue("", t, allowed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkTriggerValue(key string, v any, allowed func(string) bool) error {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, item := range v {
+			if err := checkTriggerValue(k, item, allowed); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if err := checkTriggerValue(key, item, allowed); err != nil {
+				return err
+			}
+		}
+	case []string:
+		for _, item := range v {
+			if err := checkTriggerValue(key, item, allowed); err != nil {
+				return err
+			}
+		}
+	case string:
+		if strings.Contains(v, "{{") || strings.Contains(v, "{%") {
+			return checkTemplateAccess(v, allowed)
+		}
+		// time triggers accept an input_datetime or sensor as "at".
+		if triggerEntityKeys[key] || key == "at" && entityIDRe.MatchString(v) {
+			if !allowed(v) {
+				return fmt.Errorf("entity not found: %s", v)
+			}
+		}
+	}
+	return nil
+}
+
+// formatTriggerPlain returns a compact description of a trigger firing.
+func formatTriggerPlain(vars map[string]any) string {
+	trig, _ := vars["trigger"].(map[string]any)
+	if toState, ok := trig["to_state"].(map[string]any); ok {
+		entityID, _ := trig["entity_id"].(string)
+		to, _ := toState["state"].(string)
+		if fromState, ok := trig["from_state"].(map[string]any); ok {
+			from, _ := fromState["state"].(string)
+			return fmt.Sprintf("%s: %s -> %s", entityID, from, to)
+		}
+		return fmt.Sprintf("%s: %s", entityID, to)
+	}
+	desc, _ := trig["description"].(string)
+	if desc == "" {
+		desc = triggerPlatform(trig)
+	}
+	return "trigger: " + desc
+}
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/joaobarroca93/hactl/filter"
+	"github.com/spf13/cobra"
+)
+
+func TestParseTriggers(t *testing.T) {
+	tests := []struct {
+		src  string
+		want int
+		err  string
+	}{
+		{"platform: state\nentity_id: light.desk\nto: \"on\"\n", 1, ""},
+		{"- trigger: numeric_state\n  entity_id: sensor.temp\n  below: 0\n- trigger: time\n  at: \"07:30\"\n", 2, ""},
+		{"triggers:\n  - trigger: sun\n    event: sunset\n", 1, ""},
+		{`[{"platform": "time_pattern", "minutes": "/5"}]`, 1, ""},
+		{"- entity_id: light.desk\n", 0, "trigger 1: expected a mapping with a trigger or platform key"},
+		{"[]", 0, "no triggers"},
+		{"just text", 0, "expected a trigger or a list of triggers"},
+	}
+	for _, tt := range tests {
+		got, err := parseTriggers([]byte(tt.src))
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("parseTriggers(%q): got %v, want %q", tt.src, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || len(got) != tt.want {
+			t.Errorf("parseTriggers(%q) = %d triggers, %v; want %d", tt.src, len(got), err, tt.want)
+		}
+	}
+}
+
+func TestInlineTriggers(t *testing.T) {
+	useFilter(t, filter.New("all", true))
+
+	tests := []struct {
+		flags map[string][]string
+		want  []map[string]any
+		err   string
+	}{
+		{
+			flags: map[string][]string{"entity": {"binary_sensor.door"}, "to": {"on"}, "for": {"30s"}},
+			want: []map[string]any{{
+				"platform": "state", "entity_id": []string{"binary_sensor.door"},
+				"to": "on", "for": map[string]any{"seconds": 30.0},
+			}},
+		},
+		{
+			flags: map[string][]string{"entity": {"sensor.temp"}, "below": {"0"}, "attribute": {"current"}},
+			want: []map[string]any{{
+				"platform": "numeric_state", "entity_id": []string{"sensor.temp"},
+				"below": 0.0, "attribute": "current",
+			}},
+		},
+		{
+			flags: map[string][]string{"at": {"07:30", "22:15:30", "input_datetime.wake"}},
+			want:  []map[string]any{{"platform": "time", "at": []any{"07:30", "22:15:30", "input_datetime.wake"}}},
+		},
+		{flags: map[string][]string{"to": {"on"}}, err: "--to needs --entity"},
+		{flags: map[string][]string{"entity": {"sensor.temp"}, "above": {"5"}, "to": {"on"}}, err: "--to cannot be combined"},
+		{flags: map[string][]string{"at": {"7pm"}}, err: `invalid --at "7pm"`},
+	}
+	for _, tt := range tests {
+		cmd := &cobra.Command{}
+		addTriggerFlags(cmd)
+		for name, values := range tt.flags {
+			for _, v := range values {
+				if err := cmd.Flags().Set(name, v); err != nil {
+					t.Fatalf("set --%s: %v", name, err)
+				}
+			}
+		}
+		got, err := inlineTriggers(cmd)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%v: got %v, want %q", tt.flags, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: got %#v, %v; want %#v", tt.flags, got, err, tt.want)
+		}
+	}
+}
+
+func TestCheckTriggerAccess(t *testing.T) {
+	allowed := func(id string) bool {
+		return id == "light.desk" || id == "sensor.temp" || id == "zone.home" || id == "input_datetime.wake"
+	}
+	tests := []struct {
+		src string
+		err string
+	}{
+		{"- trigger: state\n  entity_id: [light.desk]\n  to: \"on\"\n", ""},
+		{"- platform: numeric_state\n  entity_id: sensor.temp\n  below: 0\n", ""},
+		{"- trigger: state\n  entity_id: [light.desk, light.hidden]\n", "entity not found: light.hidden"},
+		{"- trigger: zone\n  entity_id: person.alice\n  zone: zone.home\n  event: enter\n", "entity not found: person.alice"},
+		{"- trigger: time\n  at: input_datetime.wake\n", ""},
+		{"- trigger: time\n  at: input_datetime.secret\n", "entity not found: input_datetime.secret"},
+		{"- trigger: time\n  at: \"07:30\"\n", ""},
+		{"- trigger: template\n  value_template: \"{{ states('sensor.temp') | float < 0 }}\"\n", ""},
+		{"- trigger: template\n  value_template: \"{{ states('sensor.secret') == 'on' }}\"\n", "entity not found: sensor.secret"},
+		{"- trigger: template\n  value_template: \"{{ states.light | list | count > 2 }}\"\n", "not permitted in exposed mode"},
+		{"- trigger: template\n  value_template: \"{{ is_state('LOCK.FRONT_DOOR','unlocked') }}\"\n", "entity not found: lock.front_door"},
+		{"- trigger: template\n  value_template: \"{{ is_state('lock' ~ '.front_door','unlocked') }}\"\n", "must be given a literal entity ID"},
+		{"- trigger: event\n  event_type: state_changed\n", "trigger platform event is not permitted in exposed mode"},
+		{"- trigger: device\n  device_id: abc\n", "trigger platform device is not permitted"},
+	}
+	for _, tt := range tests {
+		triggers, err := parseTriggers([]byte(tt.src))
+		if err != nil {
+			t.Fatalf("parse %q: %v", tt.src, err)
+		}
+		err = checkTriggerAccess(triggers, allowed)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%q: unexpected error: %v", tt.src, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%q: got %v, want %q", tt.src, err, tt.err)
+		}
+	}
+}
+
+func TestFormatTriggerPlain(t *testing.T) {
+	tests := []struct {
+		vars map[string]any
+		want string
+	}{
+		{map[string]any{"trigger": map[string]any{
+			"platform": "state", "entity_id": "binary_sensor.door",
+			"from_state": map[string]any{"state": "off"}, "to_state": map[string]any{"state": "on"},
+		}}, "binary_sensor.door: off -> on"},
+		{map[string]any{"trigger": map[string]any{"platform": "time", "description": "time"}}, "trigger: time"},
+		{map[string]any{"trigger": map[string]any{"platform": "sun"}}, "trigger: sun"},
+	}
+	for _, tt := range tests {
+		if got := formatTriggerPlain(tt.vars); got != tt.want {
+			t.Errorf("formatTriggerPlain() = %q, want %q", got, tt.want)
+		}
+	}
+}
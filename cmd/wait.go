@@ -0,0 +1,239 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/joaobarroca93/hactl/client"
+	"github.com/joaobarroca93/hactl/expr"
+	"github.com/joaobarroca93/hactl/output"
+	"github.com/spf13/cobra"
+)
+
+var stateWaitCmd = &cobra.Command{
+	Use:   "wait <entity>",
+	Short: "Block until an entity matches a condition",
+	Long: `Block until an entity reaches a state or matches a condition.
+
+hactl subscribes to state_changed events over the WebSocket API, so the
+condition is detected as soon as Home Assistant reports it — no polling.
+
+Conditions (all given conditions must hold):
+  --state    the state equals one of the given values (repeatable)
+  --attr     an attribute equals a value, as key=value (repeatable)
+  --where    an expression, as accepted by state list --where
+
+Exit codes:
+  0  the condition was met (the matching state is printed)
+  1  error
+  2  timed out before the condition was met
+
+Examples:
+  hactl state wait cover.garage --state open --timeout 60s
+  hactl state wait light.kitchen --attr brightness=255 --timeout 10s
+  hactl state wait binary_sensor.door --state off --for 30s
+  hactl state wait sensor.power --where 'float(state) < 5' --for 2m`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entityID, err := resolveEntity(args[0], "")
+		if err != nil {
+			return output.Err("%s", err)
+		}
+
+		states, _ := cmd.Flags().GetStringArray("state")
+		attrFlags, _ := cmd.Flags().GetStringArray("attr")
+		whereSrc, _ := cmd.Flags().GetString("where")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		hold, _ := cmd.Flags().GetDuration("for")
+
+		cond, err := newWaitCondition(states, attrFlags, whereSrc)
+		if err != nil {
+			return output.Err("%s", err)
+		}
+
+		ws, err := dialWS()
+		if err != nil {
+			return output.Err("%s", err)
+		}
+		sess := ws.StartSession()
+		defer sess.Close()
+
+		s, matched, err := waitForState(sess, entityID, cond, hold, timeout)
+		if err != nil {
+			return output.Err("%s", err)
+		}
+		if !matched {
+			current := "unknown"
+			if s != nil {
+				current = s.State
+			}
+			return output.Timeout("%s did not match within %s (state: %s)", entityID, timeout, current)
+		}
+
+		if quiet {
+			return nil
+		}
+		if plain {
+			output.PrintPlain(fmt.Sprintf("%s: %s", s.EntityID, s.State))
+			return nil
+		}
+		return output.PrintJSON(s)
+	},
+}
+
+func init() {
+	stateWaitCmd.Flags().StringArray("state", nil, "state to wait for (repeatable; any value matches)")
+	stateWaitCmd.Flags().StringArray("attr", nil, "attribute condition as key=value (repeatable)")
+	stateWaitCmd.Flags().String("where", "", "expression over state and attributes (see state list --help)")
+	stateWaitCmd.Flags().Duration("timeout", 0, "give up after this long and exit 2 (0 = wait forever)")
+	stateWaitCmd.Flags().Duration("for", 0, "require the condition to hold continuously for this long")
+
+	stateCmd.AddCommand(stateWaitCmd)
+}
+
+// waitCondition is the condition state wait blocks on.
+type waitCondition struct {
+	states []string
+	attrs  map[string]string
+	where  *expr.Expr
+}
+
+// newWaitCondition builds a condition from flag values. At least one of the
+// three kinds of condition must be given.
+func newWaitCondition(states, attrFlags []string, whereSrc string) (waitCondition, error) {
+	cond := waitCondition{states: states, attrs: map[string]string{}}
+	for _, kv := range attrFlags {
+		k, v, found := strings.Cut(kv, "=")
+		if !found || k == "" {
+			return cond, fmt.Errorf("--attr must be in key=value format, got: %s", kv)
+		}
+		cond.attrs[k] = v
+	}
+	if whereSrc != "" {
+		where, err := expr.Parse(whereSrc)
+		if err != nil {
+			return cond, fmt.Errorf("invalid --where expression: %s", err)
+		}
+		cond.where = where
+	}
+	if len(cond.states) == 0 && len(cond.attrs) == 0 && cond.where == nil {
+		return cond, fmt.Errorf("nothing to wait for: give --state, --attr or --where")
+	}
+	return cond, nil
+}
+
+// matches reports whether s satisfies every part of the condition.
+// A nil state (entity removed or not yet known) never matches.
+func (c waitCondition) matches(s *client.State) bool {
+	if s == nil {
+		return false
+	}
+	if len(c.states) > 0 {
+		ok := false
+		for _, want := range c.states {
+			if s.State == want {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	for k, want := range c.attrs {
+		v, ok := s.Attributes[k]
+		if !ok || fmt.Sprint(v) != want {
+			return false
+		}
+	}
+	if c.where != nil && !c.where.Match(stateEnv(*s)) {
+		return false
+	}
+	return true
+}
+
+// since returns when s started satisfying the condition, as far as the state
+// itself can tell: last_changed for pure state conditions, last_updated when
+// attributes are involved.
+func (c waitCondition) since(s *client.State) time.Time {
+	if len(c.attrs) == 0 && c.where == nil {
+		return s.LastChanged
+	}
+	return s.LastUpdated
+}
+
+// waitForState blocks until entityID satisfies cond continuously for hold, or
+// until timeout elapses (0 waits forever). It returns the last observed state
+// and whether the condition was met.
+func waitForState(sess *client.Session, entityID string, cond waitCondition, hold, timeout time.Duration) (*client.State, bool, error) {
+	// Subscribe before reading the current state so no change can slip
+	// through between the two.
+	sub, err := sess.SubscribeEvents("state_changed")
+	if err != nil {
+		return nil, false, err
+	}
+	defer sub.Unsubscribe()
+
+	current, err := getClient().GetState(entityID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		deadline = t.C
+	}
+	holdTimer := time.NewTimer(0)
+	<-holdTimer.C
+	defer holdTimer.Stop()
+
+	// check reports whether the wait is over; otherwise it (re)arms the hold
+	// timer as needed.
+	check := func(matchedAt time.Time) bool {
+		holdTimer.Stop()
+		if !cond.matches(current) {
+			return false
+		}
+		remaining := hold - time.Since(matchedAt)
+		if remaining <= 0 {
+			return true
+		}
+		holdTimer.Reset(remaining)
+		return false
+	}
+
+	if current != nil && check(cond.since(current)) {
+		return current, true, nil
+	}
+	for {
+		select {
+		case raw, ok := <-sub.C:
+			if !ok {
+				return current, false, fmt.Errorf("websocket closed: %v", sess.Err())
+			}
+			ev, err := client.ParseEvent(raw)
+			if err != nil {
+				continue
+			}
+			sc, err := ev.StateChange()
+			if err != nil || sc.EntityID != entityID {
+				continue
+			}
+			wasMatching := cond.matches(current)
+			current = sc.NewState
+			if wasMatching && cond.matches(current) {
+				continue // still matching; keep the running hold timer
+			}
+			if check(time.Now()) {
+				return current, true, nil
+			}
+		case <-holdTimer.C:
+			return current, true, nil
+		case <-deadline:
+			return current, false, nil
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joaobarroca93/hactl/client"
+	"github.com/joaobarroca93/hactl/output"
+)
+
+// --- newWaitCondition ---
+
+func TestNewWaitConditionErrors(t *testing.T) {
+	if _, err := newWaitCondition(nil, nil, ""); err == nil {
+		t.Error("expected error when no condition is given")
+	}
+	if _, err := newWaitCondition(nil, []string{"brightness"}, ""); err == nil {
+		t.Error("expected error for --attr without =")
+	}
+	if _, err := newWaitCondition(nil, nil, "state =="); err == nil {
+		t.Error("expected error for an invalid --where expression")
+	}
+}
+
+// --- waitCondition.matches ---
+
+func TestWaitConditionMatches(t *testing.T) {
+	s := &client.State{
+		EntityID:   "cover.garage",
+		State:      "open",
+		Attributes: map[string]any{"current_position": float64(100)},
+	}
+	tests := []struct {
+		name   string
+		states []string
+		attrs  []string
+		where  string
+		want   bool
+	}{
+		{"state match", []string{"open"}, nil, "", true},
+		{"state any of", []string{"opening", "open"}, nil, "", true},
+		{"state mismatch", []string{"closed"}, nil, "", false},
+		{"attr match", nil, []string{"current_position=100"}, "", true},
+		{"attr mismatch", nil, []string{"current_position=50"}, "", false},
+		{"attr missing", nil, []string{"tilt=0"}, "", false},
+		{"where", nil, nil, "attributes.current_position >= 90", true},
+		{"all conditions", []string{"open"}, []string{"current_position=100"}, `state == "open"`, true},
+		{"one failing", []string{"open"}, nil, `state == "closed"`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cond, err := newWaitCondition(tt.states, tt.attrs, tt.where)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := cond.matches(s); got != tt.want {
+				t.Errorf("matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	cond, _ := newWaitCondition([]string{"open"}, nil, "")
+	if cond.matches(nil) {
+		t.Error("a nil state must never match")
+	}
+}
+
+// --- waitForState ---
+
+// startWait runs waitForState against f in the background and waits until
+// it has subscribed, so events fired afterwards are seen.
+func startWait(t *testing.T, f *fakeHA, cond waitCondition, hold, timeout time.Duration) <-chan waitResult {
+	t.Helper()
+	ws, err := dialWS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess := ws.StartSession()
+	t.Cleanup(func() { sess.Close() })
+
+	done := make(chan waitResult, 1)
+	go func() {
+		s, matched, err := waitForState(sess, "cover.garage", cond, hold, timeout)
+		done <- waitResult{s, matched, err}
+	}()
+	select {
+	case <-f.subscribed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("waitForState did not subscribe")
+	}
+	return done
+}
+
+type waitResult struct {
+	state   *client.State
+	matched bool
+	err     error
+}
+
+func TestWaitForState(t *testing.T) {
+	open := waitCondition{states: []string{"open"}}
+
+	t.Run("already matching", func(t *testing.T) {
+		f := newFakeHA(t)
+		f.setState(client.State{EntityID: "cover.garage", State: "open"})
+		res := <-startWait(t, f, open, 0, 5*time.Second)
+		if res.err != nil || !res.matched || res.state.State != "open" {
+			t.Errorf("got %+v, want an immediate match", res)
+		}
+	})
+
+	t.Run("change arrives", func(t *testing.T) {
+		f := newFakeHA(t)
+		f.setState(client.State{EntityID: "cover.garage", State: "closed"})
+		done := startWait(t, f, open, 0, 5*time.Second)
+		time.Sleep(50 * time.Millisecond) // let the current state be read
+		f.fire(stateChangedEvent("cover.garage", "closed", "opening", nil, nil))
+		f.fire(stateChangedEvent("cover.other", "closed", "open", nil, nil))
+		f.fire(stateChangedEvent("cover.garage", "opening", "open", nil, nil))
+		res := <-done
+		if res.err != nil || !res.matched || res.state.State != "open" {
+			t.Errorf("got %+v, want a match on the open event", res)
+		}
+	})
+
+	t.Run("hold", func(t *testing.T) {
+		f := newFakeHA(t)
+		f.setState(client.State{EntityID: "cover.garage", State: "closed"})
+		done := startWait(t, f, open, 100*time.Millisecond, 5*time.Second)
+		time.Sleep(50 * time.Millisecond)
+		start := time.Now()
+		f.fire(stateChangedEvent("cover.garage", "closed", "open", nil, nil))
+		res := <-done
+		if res.err != nil || !res.matched {
+			t.Fatalf("got %+v, want a match", res)
+		}
+		if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+			t.Errorf("matched after %s, before --for elapsed", elapsed)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		f := newFakeHA(t)
+		f.setState(client.State{EntityID: "cover.garage", State: "closed"})
+		res := <-startWait(t, f, open, 0, 100*time.Millisecond)
+		if res.err != nil || res.matched {
+			t.Fatalf("got %+v, want no match and no error", res)
+		}
+		if res.state == nil || res.state.State != "closed" {
+			t.Errorf("state = %v, want the last observed state", res.state)
+		}
+	})
+
+	t.Run("connection lost", func(t *testing.T) {
+		f := newFakeHA(t)
+		f.setState(client.State{EntityID: "cover.garage", State: "closed"})
+		done := startWait(t, f, open, 0, 5*time.Second)
+		time.Sleep(50 * time.Millisecond)
+		f.dropConnections()
+		res := <-done
+		if res.err == nil || !strings.Contains(res.err.Error(), "websocket closed") {
+			t.Errorf("err = %v, want websocket closed", res.err)
+		}
+	})
+}
+
+// TestStateWaitTimeoutExitCode runs state wait in a child process, since
+// output.Timeout exits, and checks it exits with code 2.
+func TestStateWaitTimeoutExitCode(t *testing.T) {
+	if os.Getenv("HACTL_TEST_STATE_WAIT") == "1" {
+		rootCmd.SetArgs([]string{"--config", os.Getenv("HACTL_TEST_CONFIG"), "state", "wait", "cover.garage", "--state", "open", "--timeout", "100ms"})
+		Execute()
+		os.Exit(0)
+	}
+
+	f := newFakeHA(t)
+	f.setState(client.State{EntityID: "cover.garage", State: "closed"})
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(config, []byte("filter:\n  mode: all\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestStateWaitTimeoutExitCode$")
+	cmd.Env = append(os.Environ(), "HACTL_TEST_STATE_WAIT=1", "HACTL_TEST_CONFIG="+config,
+		"HOME="+dir, "HASS_URL="+f.URL, "HASS_TOKEN=test-token")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != output.ExitTimeout {
+		t.Fatalf("exit = %v, want code %d; stderr: %s", err, output.ExitTimeout, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "timeout: cover.garage did not match within 100ms (state: closed)") {
+		t.Errorf("stderr = %q", stderr.String())
+	}
+}
@@ -11,7 +11,7 @@ import (
 )
 
 var weatherCmd = &cobra.Command{
-	Use:   "weather [entity_id]",
+	Use:   "weather [entity]",
 	Short: "Show current weather conditions and forecast",
 	Long: `Shows current conditions (temperature, humidity, wind) for a weather entity.
 Includes forecast if available from the integration's state attributes.
@@ -24,12 +24,10 @@ Examples:
 	RunE: func(cmd *cobra.Command, args []string) error {
 		var entityID string
 		if len(args) == 1 {
-			entityID = args[0]
-			if !strings.HasPrefix(entityID, "weather.") {
-				entityID = "weather." + entityID
-			}
-			if !entityFilter.IsAllowed(entityID) {
-				return output.Err("entity not found: %s", entityID)
+			var err error
+			entityID, err = resolveEntity(args[0], "weather")
+			if err != nil {
+				return output.Err("%s", err)
 			}
 		} else {
 			// Find the first exposed weather entity
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"github.com/joaobarroca93/hactl/output"
+	"github.com/spf13/cobra"
+)
+
+var withRestoreCmd = &cobra.Command{
+	Use:   "with-restore <entity>... -- <command> [args...]",
+	Short: "Run a command, then put entities back the way they were",
+	Long: `Capture the state of entities into a temporary scene, run a command, and
+restore the scene when the command exits — whether it succeeds, fails or is
+interrupted. hactl exits with the command's exit code.
+
+Examples:
+  hactl with-restore light.living_room light.kitchen -- ./flash-lights.sh
+  hactl with-restore 'light.*' -- hactl service call light.turn_on --entity 'light.*' --rgb 255,0,0`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		dash := cmd.ArgsLenAtDash()
+		if dash < 1 || dash == len(args) {
+			return fmt.Errorf("usage: hactl with-restore <entity>... -- <command> [args...]")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dash := cmd.ArgsLenAtDash()
+		refs, command := args[:dash], args[dash:]
+
+		entities, err := resolveServiceEntities(refs, "")
+		if err != nil {
+			return output.Err("%s", err)
+		}
+		rec, err := captureScene(fmt.Sprintf("hactl_restore_%d", os.Getpid()), entities)
+		if err != nil {
+			return output.Err("capture: %s", err)
+		}
+
+		code, runErr := runRestoring(command)
+
+		_, restoreErr := restoreScene(rec)
+		if restoreErr == nil {
+			restoreErr = deleteScene(rec)
+		}
+		if runErr != nil {
+			return output.Err("%s", runErr)
+		}
+		if restoreErr != nil {
+			return output.Err("restore %s: %s", rec.EntityID, restoreErr)
+		}
+		if code != 0 {
+			os.Exit(code)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(withRestoreCmd)
+}
+
+// runRestoring runs command with hactl's standard streams and returns its
+// exit code. Interrupts are passed on to the command instead of ending hactl,
+// so the restore still happens.
+func runRestoring(command []string) (int, error) {
+	c := exec.Command(command[0], command[1:]...)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	if err := c.Start(); err != nil {
+		return 0, fmt.Errorf("run %s: %w", command[0], err)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sig := <-sigs:
+				c.Process.Signal(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err := c.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if code := exitErr.ExitCode(); code >= 0 {
+			return code, nil
+		}
+		return 1, nil // killed by a signal
+	}
+	if err != nil {
+		return 0, fmt.Errorf("run %s: %w", command[0], err)
+	}
+	return 0, nil
+}
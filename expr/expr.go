@@ -0,0 +1,382 @@
+// Package expr implements the small boolean expression language used by
+// --where, --until and similar flags.
+//
+// Expressions compare fields of an environment (a map, usually built from an
+// entity state or an event) against literals:
+//
+//	state == "on" && attributes.brightness > 100
+//	attributes.device_class == "battery" && float(state) < 20
+//	last_changed < -2h
+//
+// Identifiers are dotted paths into the environment. Missing fields evaluate
+// to null, and comparisons between incompatible values are simply false, so
+// an expression like attributes.brightness > 100 never fails on entities that
+// have no brightness. Duration literals (30s, 5m, -2h, 7d) compared against a
+// time are interpreted relative to now: last_changed < -2h means "changed more
+// than two hours ago".
+package expr
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// now is replaced in tests.
+var now = time.Now
+
+// Expr is a parsed expression ready to be evaluated.
+type Expr struct {
+	src  string
+	root node
+}
+
+// Parse compiles src into an Expr.
+func Parse(src string) (*Expr, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", t.text, t.pos+1)
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+// String returns the source text of the expression.
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Eval evaluates the expression against env and returns its value.
+func (e *Expr) Eval(env map[string]any) any {
+	return e.root.eval(env)
+}
+
+// Match evaluates the expression against env and reports whether the result
+// is truthy.
+func (e *Expr) Match(env map[string]any) bool {
+	return truthy(e.root.eval(env))
+}
+
+// Lookup resolves a dotted path such as "attributes.brightness" in env.
+// It returns nil when any segment is missing.
+func Lookup(env map[string]any, path string) any {
+	var cur any = env
+	for _, seg := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil
+		}
+		cur, ok = m[seg]
+		if !ok {
+			return nil
+		}
+	}
+	return cur
+}
+
+// Compare orders a and b using the same coercion rules as the comparison
+// operators. ok is false when the values cannot be ordered.
+func Compare(a, b any) (c int, ok bool) {
+	return compare(a, b)
+}
+
+// ---------------------------------------------------------------------------
+// AST
+
+type node interface {
+	eval(env map[string]any) any
+}
+
+type litNode struct{ v any }
+
+func (n litNode) eval(map[string]any) any { return n.v }
+
+type identNode struct{ path string }
+
+func (n identNode) eval(env map[string]any) any { return Lookup(env, n.path) }
+
+type notNode struct{ x node }
+
+func (n notNode) eval(env map[string]any) any { return !truthy(n.x.eval(env)) }
+
+type negNode struct{ x node }
+
+func (n negNode) eval(env map[string]any) any {
+	switch v := n.x.eval(env).(type) {
+	case time.Duration:
+		return -v
+	case float64:
+		return -v
+	}
+	return nil
+}
+
+type logicNode struct {
+	op   string
+	l, r node
+}
+
+func (n logicNode) eval(env map[string]any) any {
+	l := truthy(n.l.eval(env))
+	if n.op == "&&" {
+		return l && truthy(n.r.eval(env))
+	}
+	return l || truthy(n.r.eval(env))
+}
+
+type cmpNode struct {
+	op   string
+	l, r node
+	re   *regexp.Regexp // precompiled for =~ with a literal pattern
+}
+
+func (n cmpNode) eval(env map[string]any) any {
+	l, r := n.l.eval(env), n.r.eval(env)
+	switch n.op {
+	case "=~":
+		s, ok := l.(string)
+		if !ok {
+			return false
+		}
+		re := n.re
+		if re == nil {
+			pat, ok := r.(string)
+			if !ok {
+				return false
+			}
+			var err error
+			if re, err = regexp.Compile(pat); err != nil {
+				return false
+			}
+		}
+		return re.MatchString(s)
+	case "==":
+		return equal(l, r)
+	case "!=":
+		return !equal(l, r)
+	}
+	c, ok := compare(l, r)
+	if !ok {
+		return false
+	}
+	switch n.op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+type callNode struct {
+	name string
+	fn   func(args []any) any
+	args []node
+}
+
+func (n callNode) eval(env map[string]any) any {
+	vals := make([]any, len(n.args))
+	for i, a := range n.args {
+		vals[i] = a.eval(env)
+	}
+	return n.fn(vals)
+}
+
+// funcs are the built-in functions. Each returns nil when its argument
+// cannot be converted, which makes any comparison against it false.
+var funcs = map[string]struct {
+	arity int
+	fn    func(args []any) any
+}{
+	"float": {1, func(a []any) any {
+		if f, ok := toNumber(a[0], true); ok {
+			return f
+		}
+		return nil
+	}},
+	"int": {1, func(a []any) any {
+		if f, ok := toNumber(a[0], true); ok {
+			return float64(int64(f))
+		}
+		return nil
+	}},
+	"str": {1, func(a []any) any {
+		if a[0] == nil {
+			return ""
+		}
+		if s, ok := a[0].(string); ok {
+			return s
+		}
+		return fmt.Sprint(a[0])
+	}},
+	"lower": {1, func(a []any) any {
+		if s, ok := a[0].(string); ok {
+			return strings.ToLower(s)
+		}
+		return nil
+	}},
+	"len": {1, func(a []any) any {
+		switch v := a[0].(type) {
+		case string:
+			return float64(len(v))
+		case []any:
+			return float64(len(v))
+		case map[string]any:
+			return float64(len(v))
+		}
+		return nil
+	}},
+	"contains": {2, func(a []any) any {
+		switch v := a[0].(type) {
+		case string:
+			s, ok := a[1].(string)
+			return ok && strings.Contains(v, s)
+		case []any:
+			for _, item := range v {
+				if equal(item, a[1]) {
+					return true
+				}
+			}
+		}
+		return false
+	}},
+}
+
+// ---------------------------------------------------------------------------
+// Value semantics
+
+func truthy(v any) bool {
+	switch x := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return x
+	case string:
+		return x != ""
+	case float64:
+		return x != 0
+	case []any:
+		return len(x) > 0
+	case map[string]any:
+		return len(x) > 0
+	}
+	return true
+}
+
+// toNumber converts numeric values to float64. Strings are parsed only when
+// parseStrings is true, so "10" == 10 works but "10" < "9" stays a string
+// comparison.
+func toNumber(v any, parseStrings bool) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case bool:
+		return 0, false
+	case string:
+		if !parseStrings {
+			return 0, false
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func toTime(v any) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, true
+	case time.Duration:
+		return now().Add(t), true
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"} {
+			if parsed, err := time.ParseInLocation(layout, t, time.Local); err == nil {
+				return parsed, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+func isNumber(v any) bool {
+	_, ok := toNumber(v, false)
+	return ok
+}
+
+func equal(a, b any) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if c, ok := compare(a, b); ok {
+		return c == 0
+	}
+	if ab, ok := a.(bool); ok {
+		bb, ok := b.(bool)
+		return ok && ab == bb
+	}
+	return false
+}
+
+func compare(a, b any) (int, bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+	_, aTime := a.(time.Time)
+	_, bTime := b.(time.Time)
+	if aTime || bTime {
+		at, ok1 := toTime(a)
+		bt, ok2 := toTime(b)
+		if !ok1 || !ok2 {
+			return 0, false
+		}
+		return at.Compare(bt), true
+	}
+	ad, aDur := a.(time.Duration)
+	bd, bDur := b.(time.Duration)
+	if aDur && bDur {
+		return cmpOrdered(ad, bd), true
+	}
+	if isNumber(a) || isNumber(b) {
+		af, ok1 := toNumber(a, true)
+		bf, ok2 := toNumber(b, true)
+		if !ok1 || !ok2 {
+			return 0, false
+		}
+		return cmpOrdered(af, bf), true
+	}
+	as, ok1 := a.(string)
+	bs, ok2 := b.(string)
+	if ok1 && ok2 {
+		return strings.Compare(as, bs), true
+	}
+	return 0, false
+}
+
+func cmpOrdered[T float64 | time.Duration](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
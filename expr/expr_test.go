@@ -0,0 +1,176 @@
+package expr
+
+import (
+	"testing"
+	"time"
+)
+
+func testEnv() map[string]any {
+	return map[string]any{
+		"entity_id": "light.kitchen",
+		"domain":    "light",
+		"state":     "on",
+		"attributes": map[string]any{
+			"brightness":    float64(180),
+			"friendly_name": "Kitchen Light",
+			"device_class":  "battery",
+			"effect_list":   []any{"rainbow", "strobe"},
+		},
+		"last_changed": time.Now().Add(-3 * time.Hour),
+	}
+}
+
+// --- Parse / Match ---
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{`state == "on"`, true},
+		{`state != "on"`, false},
+		{`state == 'on' && attributes.brightness > 100`, true},
+		{`state == "on" && attributes.brightness > 200`, false},
+		{`state == "off" || attributes.brightness >= 180`, true},
+		{`!(state == "off")`, true},
+		{`not state == "off"`, true},
+		{`state == "on" and domain == "light"`, true},
+		{`attributes.missing > 100`, false},
+		{`attributes.missing == null`, true},
+		{`attributes.missing`, false},
+		{`attributes.device_class == "battery"`, true},
+		{`float(state) < 20`, false},
+		{`last_changed < -2h`, true},
+		{`last_changed > -2h`, false},
+		{`last_changed < -1d`, false},
+		{`entity_id =~ "^light\\."`, true},
+		{`lower(attributes.friendly_name) == "kitchen light"`, true},
+		{`contains(attributes.effect_list, "strobe")`, true},
+		{`contains(entity_id, "bedroom")`, false},
+		{`len(attributes.effect_list) == 2`, true},
+	}
+	env := testEnv()
+	for _, tt := range tests {
+		e, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.src, err)
+			continue
+		}
+		if got := e.Match(env); got != tt.want {
+			t.Errorf("Match(%q) = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestMatchNumericState(t *testing.T) {
+	env := map[string]any{"state": "15", "attributes": map[string]any{"device_class": "battery"}}
+	for _, src := range []string{
+		`attributes.device_class == "battery" && float(state) < 20`,
+		`state < 20`,
+		`state == 15`,
+		`int(state) == 15`,
+	} {
+		e, err := Parse(src)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", src, err)
+		}
+		if !e.Match(env) {
+			t.Errorf("Match(%q) = false, want true", src)
+		}
+	}
+
+	e, _ := Parse(`float(state) < 20`)
+	if e.Match(map[string]any{"state": "unavailable"}) {
+		t.Error("float() of a non-numeric state should not match")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	bad := []string{
+		``,
+		`state ==`,
+		`state == "on`,
+		`(state == "on"`,
+		`foo(state)`,
+		`float(state, 1)`,
+		`state == 5x`,
+		`state =~ "("`,
+		`state @ 1`,
+	}
+	for _, src := range bad {
+		if _, err := Parse(src); err == nil {
+			t.Errorf("Parse(%q): expected error", src)
+		}
+	}
+}
+
+// --- Lookup ---
+
+func TestLookup(t *testing.T) {
+	env := testEnv()
+	if got := Lookup(env, "attributes.brightness"); got != float64(180) {
+		t.Errorf("Lookup(attributes.brightness) = %v", got)
+	}
+	if got := Lookup(env, "attributes.brightness.x"); got != nil {
+		t.Errorf("Lookup through a scalar = %v, want nil", got)
+	}
+	if got := Lookup(env, "nope"); got != nil {
+		t.Errorf("Lookup(nope) = %v, want nil", got)
+	}
+}
+
+// --- Compare ---
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b any
+		want int
+		ok   bool
+	}{
+		{float64(1), float64(2), -1, true},
+		{"10", float64(9), 1, true},
+		{"10", "9", -1, true},
+		{"b", "a", 1, true},
+		{nil, "a", 0, false},
+		{"abc", float64(1), 0, false},
+		{time.Unix(10, 0), time.Unix(20, 0), -1, true},
+	}
+	for _, tt := range tests {
+		got, ok := Compare(tt.a, tt.b)
+		if ok != tt.ok || ok && got != tt.want {
+			t.Errorf("Compare(%v, %v) = %d, %v; want %d, %v", tt.a, tt.b, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// --- ParseDuration ---
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"30s", 30 * time.Second},
+		{"2h", 2 * time.Hour},
+		{"-2h", -2 * time.Hour},
+		{"30d", 30 * 24 * time.Hour},
+		{"2w", 14 * 24 * time.Hour},
+		{"1d12h", 36 * time.Hour},
+		{"1.5h", 90 * time.Minute},
+	}
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.in)
+		if err != nil {
+			t.Errorf("ParseDuration(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseDuration(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+	for _, bad := range []string{"", "-", "d", "5", "5y"} {
+		if _, err := ParseDuration(bad); err == nil {
+			t.Errorf("ParseDuration(%q): expected error", bad)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+package expr
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokDuration
+	tokOp
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type token struct {
+	kind tokKind
+	text string
+	val  any
+	pos  int
+}
+
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "=~", "<", ">", "!", "-"}
+
+func lex(src string) ([]token, error) {
+	var toks []token
+	i := 0
+	for i < len(src) {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(':
+			toks = append(toks, token{kind: tokLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			toks = append(toks, token{kind: tokRParen, text: ")", pos: i})
+			i++
+		case c == ',':
+			toks = append(toks, token{kind: tokComma, text: ",", pos: i})
+			i++
+		case c == '"' || c == '\'':
+			start := i
+			var sb strings.Builder
+			i++
+			for i < len(src) && rune(src[i]) != c {
+				if src[i] == '\\' && i+1 < len(src) {
+					i++
+				}
+				sb.WriteByte(src[i])
+				i++
+			}
+			if i >= len(src) {
+				return nil, fmt.Errorf("unterminated string at position %d", start+1)
+			}
+			i++
+			toks = append(toks, token{kind: tokString, text: src[start:i], val: sb.String(), pos: start})
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(src) && src[i+1] >= '0' && src[i+1] <= '9':
+			start := i
+			for i < len(src) && (src[i] >= '0' && src[i] <= '9' || src[i] == '.') {
+				i++
+			}
+			if i < len(src) && isLetter(src[i]) {
+				for i < len(src) && (isLetter(src[i]) || src[i] >= '0' && src[i] <= '9' || src[i] == '.') {
+					i++
+				}
+				d, err := ParseDuration(src[start:i])
+				if err != nil {
+					return nil, fmt.Errorf("invalid duration %q at position %d", src[start:i], start+1)
+				}
+				toks = append(toks, token{kind: tokDuration, text: src[start:i], val: d, pos: start})
+				continue
+			}
+			f, err := strconv.ParseFloat(src[start:i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q at position %d", src[start:i], start+1)
+			}
+			toks = append(toks, token{kind: tokNumber, text: src[start:i], val: f, pos: start})
+		case isLetter(src[i]) || c == '_':
+			start := i
+			for i < len(src) && (isLetter(src[i]) || src[i] == '_' || src[i] == '.' || src[i] >= '0' && src[i] <= '9') {
+				i++
+			}
+			toks = append(toks, token{kind: tokIdent, text: src[start:i], pos: start})
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(src[i:], op) {
+					toks = append(toks, token{kind: tokOp, text: op, pos: i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q at position %d", c, i+1)
+			}
+		}
+	}
+	toks = append(toks, token{kind: tokEOF, text: "end of expression", pos: len(src)})
+	return toks, nil
+}
+
+func isLetter(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
+
+// ParseDuration parses a Go duration string extended with "d" (days) and
+// "w" (weeks) units, e.g. "30d", "2w", "1d12h".
+func ParseDuration(s string) (time.Duration, error) {
+	neg := strings.HasPrefix(s, "-")
+	rest := strings.TrimPrefix(s, "-")
+	if rest == "" {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	var total time.Duration
+	for rest != "" {
+		j := 0
+		for j < len(rest) && (rest[j] >= '0' && rest[j] <= '9' || rest[j] == '.') {
+			j++
+		}
+		k := j
+		for k < len(rest) && isLetter(rest[k]) {
+			k++
+		}
+		if j == 0 || k == j {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		num, unit := rest[:j], rest[j:k]
+		rest = rest[k:]
+		switch unit {
+		case "d", "w":
+			f, err := strconv.ParseFloat(num, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration %q", s)
+			}
+			day := 24 * time.Hour
+			if unit == "w" {
+				day *= 7
+			}
+			total += time.Duration(f * float64(day))
+		default:
+			d, err := time.ParseDuration(num + unit)
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration %q", s)
+			}
+			total += d
+		}
+	}
+	if neg {
+		total = -total
+	}
+	return total, nil
+}
+
+type parser struct {
+	toks []token
+	i    int
+}
+
+func (p *parser) peek() token { return p.toks[p.i] }
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) isOp(ops ...string) bool {
+	t := p.peek()
+	for _, op := range ops {
+		if t.kind == tokOp && t.text == op || t.kind == tokIdent && t.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) parseOr() (node, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("||", "or") {
+		p.next()
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = logicNode{op: "||", l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	l, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("&&", "and") {
+		p.next()
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l = logicNode{op: "&&", l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.isOp("!", "not") {
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{x: x}, nil
+	}
+	return p.parseCmp()
+}
+
+func (p *parser) parseCmp() (node, error) {
+	l, err := p.parseSigned()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isOp("==", "!=", "<", "<=", ">", ">=", "=~") {
+		return l, nil
+	}
+	op := p.next()
+	r, err := p.parseSigned()
+	if err != nil {
+		return nil, err
+	}
+	n := cmpNode{op: op.text, l: l, r: r}
+	if op.text == "=~" {
+		if lit, ok := r.(litNode); ok {
+			pat, ok := lit.v.(string)
+			if !ok {
+				return nil, fmt.Errorf("=~ requires a string pattern at position %d", op.pos+1)
+			}
+			re, err := regexp.Compile(pat)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", pat, err)
+			}
+			n.re = re
+		}
+	}
+	return n, nil
+}
+
+func (p *parser) parseSigned() (node, error) {
+	if p.isOp("-") {
+		p.next()
+		x, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		if lit, ok := x.(litNode); ok {
+			return litNode{v: negNode{x: lit}.eval(nil)}, nil
+		}
+		return negNode{x: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString, tokNumber, tokDuration:
+		return litNode{v: t.val}, nil
+	case tokLParen:
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().kind != tokRParen {
+			return nil, fmt.Errorf("expected ) at position %d", p.peek().pos+1)
+		}
+		p.next()
+		return x, nil
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return litNode{v: true}, nil
+		case "false":
+			return litNode{v: false}, nil
+		case "null", "none":
+			return litNode{v: nil}, nil
+		}
+		if p.peek().kind != tokLParen {
+			return identNode{path: t.text}, nil
+		}
+		f, ok := funcs[t.text]
+		if !ok {
+			return nil, fmt.Errorf("unknown function %q at position %d", t.text, t.pos+1)
+		}
+		p.next()
+		var args []node
+		for p.peek().kind != tokRParen {
+			a, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, a)
+			if p.peek().kind == tokComma {
+				p.next()
+				continue
+			}
+			if p.peek().kind != tokRParen {
+				return nil, fmt.Errorf("expected , or ) at position %d", p.peek().pos+1)
+			}
+		}
+		p.next()
+		if len(args) != f.arity {
+			return nil, fmt.Errorf("%s() takes %d argument(s), got %d", t.text, f.arity, len(args))
+		}
+		return callNode{name: t.text, fn: f.fn, args: args}, nil
+	}
+	return nil, fmt.Errorf("unexpected %s at position %d", t.text, t.pos+1)
+}
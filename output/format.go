@@ -32,3 +32,17 @@ func Fatal(format string, args ...any) {
 	fmt.Fprintf(os.Stderr, "error: "+format+"\n", args...)
 	os.Exit(1)
 }
+
+// Exit codes. Commands that wait for a condition exit ExitTimeout when the
+// condition was not met in time, so scripts can tell it apart from failures.
+const (
+	ExitError   = 1
+	ExitTimeout = 2
+)
+
+// Timeout writes a formatted message to stderr and exits with ExitTimeout.
+func Timeout(format string, args ...any) error {
+	fmt.Fprintf(os.Stderr, "timeout: "+format+"\n", args...)
+	os.Exit(ExitTimeout)
+	return nil // unreachable, keeps compiler happy
+}